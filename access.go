@@ -0,0 +1,89 @@
+package hotcache
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// KeyStat is how often and how recently a key has been used, see TopAccessed.
+type KeyStat struct {
+	Key string
+	// AccessCount is the number of lookups that found the key since it was set.
+	AccessCount uint64
+	// LastAccess is when the key was last found by a lookup in local time, or the zero time if it hasn't been.
+	LastAccess time.Time
+}
+
+// keyAccess counts the uses of a value when WithAccessTracking is set. It's shared by every copy of a value, so it's
+// updated atomically, without holding the store mutex for writing.
+type keyAccess struct {
+	count uint64
+	last  int64
+}
+
+// TopAccessed returns up to n of the most used keys with WithAccessTracking, most used first. Keys used equally often
+// are ordered by how recently they were used, most recent first. It returns nil without WithAccessTracking.
+func (h *Hotcache) TopAccessed(n int) []KeyStat {
+	return h.accessed(n, func(a, b KeyStat) bool {
+		if a.AccessCount != b.AccessCount {
+			return a.AccessCount > b.AccessCount
+		}
+		return a.LastAccess.After(b.LastAccess)
+	})
+}
+
+// BottomAccessed returns up to n of the least used keys with WithAccessTracking, least used first. Keys used equally
+// often are ordered by how recently they were used, least recent first. It returns nil without WithAccessTracking.
+func (h *Hotcache) BottomAccessed(n int) []KeyStat {
+	return h.accessed(n, func(a, b KeyStat) bool {
+		if a.AccessCount != b.AccessCount {
+			return a.AccessCount < b.AccessCount
+		}
+		return a.LastAccess.Before(b.LastAccess)
+	})
+}
+
+// accessed takes a snapshot of the stats of every key that hasn't expired, and returns the first n by less.
+func (h *Hotcache) accessed(n int, less func(a, b KeyStat) bool) []KeyStat {
+	if !h.options.accessTracking || n <= 0 {
+		return nil
+	}
+
+	stats := make([]KeyStat, 0, h.LenApprox())
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if !val.live(now) || val.access == nil {
+				continue
+			}
+
+			stat := KeyStat{Key: key, AccessCount: atomic.LoadUint64(&val.access.count)}
+			if last := atomic.LoadInt64(&val.access.last); last != 0 {
+				stat.LastAccess = time.Unix(0, last)
+			}
+			stats = append(stats, stat)
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return less(stats[i], stats[j])
+	})
+
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// recordAccess counts a use of a value, if its uses are being tracked.
+func (h *Hotcache) recordAccess(val *cacheValue) {
+	if val.access == nil {
+		return
+	}
+	atomic.AddUint64(&val.access.count, 1)
+	atomic.StoreInt64(&val.access.last, h.now().UnixNano())
+}
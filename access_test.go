@@ -0,0 +1,78 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopAccessed(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithAccessTracking(true))
+	defer cache.Stop()
+
+	cache.Set("hot", "xd", 0)
+	cache.Set("warm", "xd", 0)
+	cache.Set("warm2", "xd", 0)
+	cache.Set("cold", "xd", 0)
+
+	for i := 0; i < 3; i++ {
+		cache.Get("hot")
+	}
+	cache.Get("warm")
+	clock.Advance(time.Second)
+	cache.GetMulti([]string{"warm2", "missing"})
+
+	// Access times are reported in local time.
+	now := clock.Now().Local()
+	start := now.Add(-time.Second)
+	assert.Equal(t, cache.TopAccessed(3), []KeyStat{
+		{Key: "hot", AccessCount: 3, LastAccess: start},
+		{Key: "warm2", AccessCount: 1, LastAccess: now},
+		{Key: "warm", AccessCount: 1, LastAccess: start},
+	})
+	assert.Equal(t, cache.BottomAccessed(2), []KeyStat{
+		{Key: "cold"},
+		{Key: "warm", AccessCount: 1, LastAccess: start},
+	})
+	assert.Len(t, cache.TopAccessed(10), 4)
+	assert.Nil(t, cache.TopAccessed(0))
+}
+
+func TestAccessedReset(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithAccessTracking(true))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Get("xd")
+	cache.Get("xd")
+
+	// Renaming a key keeps its counts, setting it again resets them.
+	cache.Rename("xd", "xd2")
+	assert.Equal(t, cache.TopAccessed(1), []KeyStat{{Key: "xd2", AccessCount: 2, LastAccess: clock.Now().Local()}})
+
+	clone := cache.Clone()
+	defer clone.Stop()
+	assert.Equal(t, clone.TopAccessed(1), []KeyStat{{Key: "xd2"}})
+
+	cache.Set("xd2", "xd", 0)
+	assert.Equal(t, cache.TopAccessed(1), []KeyStat{{Key: "xd2"}})
+
+	// Expired keys are left out.
+	cache.Set("xd2", "xd", time.Second)
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.TopAccessed(1), []KeyStat{})
+}
+
+func TestAccessedDisabled(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Get("xd")
+
+	assert.Nil(t, cache.TopAccessed(1))
+	assert.Nil(t, cache.BottomAccessed(1))
+}
@@ -0,0 +1,158 @@
+package hotcache
+
+import "time"
+
+// Batcher queues operations to apply together with Exec, create one with Batch. A Batcher isn't safe for concurrent
+// use, though any number of them can be executed at once.
+type Batcher struct {
+	cache *Hotcache
+	ops   []batchOp
+}
+
+// batchOpType is the kind of operation queued in a Batcher.
+type batchOpType int
+
+const (
+	batchSet batchOpType = iota
+	batchDelete
+	batchIncrement
+	batchGet
+)
+
+// batchOp is an operation queued in a Batcher, with only the fields its type uses set.
+type batchOp struct {
+	typ        batchOpType
+	key        string
+	value      interface{}
+	expiration time.Duration
+	delta      int64
+}
+
+// BatchResult is the result of an operation applied by Exec.
+type BatchResult struct {
+	// Value is the value found by Get or the new total from Increment, nil for every other operation.
+	Value interface{}
+	// OK is whether Set stored the value, Delete removed a key that hadn't expired, Increment succeeded, or Get found
+	// the key.
+	OK bool
+	// Err is why the operation failed, ErrValueTooLarge for Set or ErrNotInt64 for Increment.
+	Err error
+}
+
+// Batch starts a batch of operations that are applied together by Exec, which locks every shard they touch once rather
+// than once per operation.
+func (h *Hotcache) Batch() *Batcher {
+	return &Batcher{cache: h}
+}
+
+// Set queues a Set, see Hotcache.Set. Values larger than WithMaxValueBytes allows fail with ErrValueTooLarge.
+func (b *Batcher) Set(key string, value interface{}, expiration time.Duration) *Batcher {
+	b.ops = append(b.ops, batchOp{typ: batchSet, key: key, value: value, expiration: expiration})
+	return b
+}
+
+// Delete queues a Delete, see Hotcache.Delete.
+func (b *Batcher) Delete(key string) *Batcher {
+	b.ops = append(b.ops, batchOp{typ: batchDelete, key: key})
+	return b
+}
+
+// Increment queues an Increment, see Hotcache.Increment.
+func (b *Batcher) Increment(key string, delta int64) *Batcher {
+	b.ops = append(b.ops, batchOp{typ: batchIncrement, key: key, delta: delta})
+	return b
+}
+
+// Get queues a Get, see Hotcache.Get. It sees the changes made by operations queued before it.
+func (b *Batcher) Get(key string) *Batcher {
+	b.ops = append(b.ops, batchOp{typ: batchGet, key: key})
+	return b
+}
+
+// Len returns the number of operations queued.
+func (b *Batcher) Len() int {
+	return len(b.ops)
+}
+
+// Exec applies every queued operation in the order they were queued and returns their results in the same order,
+// leaving the Batcher empty so it can be reused.
+//
+// Every shard the batch touches is locked for the whole batch, so other callers see either none of its changes or all
+// of them. It isn't a transaction though: an operation that fails doesn't stop the rest, and changes made before it
+// aren't rolled back. With WithReadOptimized, lookups don't take locks, so they can briefly see the changes to one
+// shard before another.
+func (b *Batcher) Exec() []BatchResult {
+	h := b.cache
+	ops := b.ops
+	b.ops = nil
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	touched := make([]bool, len(h.shards))
+	for _, op := range ops {
+		touched[h.shard(op.key).index] = true
+	}
+	var shards []*shard
+	for i, s := range h.shards {
+		if touched[i] {
+			shards = append(shards, s)
+		}
+	}
+
+	h.lockShards(shards)
+
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = h.applyBatchOp(h.shard(op.key), op)
+	}
+
+	h.afterUnlock(h.unlockShards(shards))
+	return results
+}
+
+// applyBatchOp applies an operation queued in a Batcher, assumes the store mutex is held.
+func (h *Hotcache) applyBatchOp(s *shard, op batchOp) BatchResult {
+	switch op.typ {
+	case batchSet:
+		if h.tooLarge(op.value) {
+			return BatchResult{Err: ErrValueTooLarge}
+		}
+		h.set(s, op.key, op.value, op.expiration)
+		return BatchResult{OK: true}
+
+	case batchDelete:
+		val, ok := s.store[op.key]
+		if !ok {
+			return BatchResult{}
+		}
+		if val.expired(h.now()) {
+			h.evict(s, op.key)
+			return BatchResult{}
+		}
+		h.remove(s, op.key, val, ReasonDeleted)
+		return BatchResult{OK: !val.negative}
+
+	case batchIncrement:
+		total, err := h.increment(s, op.key, op.delta)
+		if err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Value: total, OK: true}
+
+	default:
+		val, ok := s.store[op.key]
+		expired := ok && val.expired(h.now())
+		ok = ok && !expired && !val.negative
+		h.recordLookup(ok)
+		if expired {
+			h.evict(s, op.key)
+		}
+		if !ok {
+			return BatchResult{}
+		}
+		h.access(op.key, val)
+		return BatchResult{Value: val.value, OK: true}
+	}
+}
@@ -0,0 +1,124 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	cache := New(WithMaxValueBytes(4))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("str", "xd", 0)
+
+	results := cache.Batch().
+		Set("xd2", "xd2", 0).
+		Set("large", "xdxdx", 0).
+		Increment("count", 2).
+		Increment("count", 3).
+		Increment("str", 1).
+		Delete("xd").
+		Delete("missing").
+		Get("xd2").
+		Get("xd").
+		Exec()
+
+	assert.Equal(t, results, []BatchResult{
+		{OK: true},
+		{Err: ErrValueTooLarge},
+		{Value: int64(2), OK: true},
+		{Value: int64(5), OK: true},
+		{Err: ErrNotInt64},
+		{OK: true},
+		{},
+		{Value: "xd2", OK: true},
+		{},
+	})
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd2", "count", "str"})
+}
+
+func TestBatchReuse(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	batch := cache.Batch()
+	assert.Nil(t, batch.Exec())
+
+	batch.Set("xd", "xd", 0)
+	assert.Equal(t, batch.Len(), 1)
+	assert.Equal(t, batch.Exec(), []BatchResult{{OK: true}})
+
+	// Executing empties the batch.
+	assert.Equal(t, batch.Len(), 0)
+	assert.Equal(t, batch.Get("xd").Exec(), []BatchResult{{Value: "xd", OK: true}})
+}
+
+func TestBatchExpiry(t *testing.T) {
+	clock := newFakeClock()
+	recorder := &evictionRecorder{}
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	cache.Set("xd2", "xd2", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	// Deleting a key that's already expired reports it as expired, the same as every other way of removing it.
+	results := cache.Batch().Get("xd").Delete("xd2").Set("xd3", "xd3", time.Second).Exec()
+	assert.Equal(t, results, []BatchResult{{}, {}, {OK: true}})
+	assert.ElementsMatch(t, recorder.get(), []eviction{
+		{key: "xd", value: "xd", reason: ReasonExpired},
+		{key: "xd2", value: "xd2", reason: ReasonExpired},
+	})
+	assert.ElementsMatch(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventExpired, Value: "xd"},
+		{Key: "xd2", Type: EventExpired, Value: "xd2"},
+		{Key: "xd3", Type: EventSet, Value: "xd3"},
+	})
+	assertExpiryTracked(t, cache)
+}
+
+func TestBatchAtomic(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	// The keys have to be in different shards for the reader to be able to see a partial batch.
+	a, b := "a", "b"
+	assert.NotEqual(t, cache.shard(a), cache.shard(b))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			cache.Batch().Increment(a, 1).Increment(b, 1).Exec()
+		}
+	}()
+
+	// Both keys are always incremented together, so b, being read after a, can never be behind it.
+	for {
+		select {
+		case <-done:
+			total, _ := cache.Get(b)
+			assert.Equal(t, total, int64(10000))
+			return
+		default:
+		}
+
+		countA, _ := cache.Get(a)
+		countB, _ := cache.Get(b)
+		if countA == nil {
+			continue
+		}
+		if !assert.NotNil(t, countB) || !assert.GreaterOrEqual(t, countB.(int64), countA.(int64)) {
+			<-done
+			return
+		}
+	}
+}
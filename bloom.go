@@ -0,0 +1,126 @@
+package hotcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// bloom is a bloom filter of keys. Bits are set atomically so it can be used without a lock, and are never cleared.
+type bloom struct {
+	bits   []uint64
+	hashes uint64
+}
+
+func newBloom(size, hashes uint) *bloom {
+	return &bloom{
+		bits:   make([]uint64, (size+63)/64),
+		hashes: uint64(hashes),
+	}
+}
+
+// add sets every bit for a key.
+func (b *bloom) add(key string) {
+	h1, h2 := bloomHash(key)
+	size := uint64(len(b.bits)) * 64
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		word, mask := &b.bits[bit/64], uint64(1)<<(bit%64)
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// mayContain checks whether every bit for a key is set, a false result means the key was definitely never added.
+func (b *bloom) mayContain(key string) bool {
+	h1, h2 := bloomHash(key)
+	size := uint64(len(b.bits)) * 64
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		if atomic.LoadUint64(&b.bits[bit/64])&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash splits the 64-bit FNV-1a hash of a key into the two hashes every bit location is derived from. The second
+// is odd so locations don't repeat.
+func bloomHash(key string) (uint64, uint64) {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return hash & 0xffffffff, hash>>32 | 1
+}
+
+// negativeBloom lets lookups skip keys that were never set without locking the store, see WithNegativeBloom.
+//
+// Bloom filters can't remove keys, so the filter is rebuilt from the store once more keys have been removed since the
+// last rebuild than are in cache. While it's being rebuilt new keys are added to both filters, and lookups keep using
+// the current one until the new one holds every key.
+type negativeBloom struct {
+	size, hashes uint
+	current      atomic.Pointer[bloom]
+	rebuilding   atomic.Pointer[bloom]
+
+	// Number of keys removed since the filter was last rebuilt.
+	removed int64
+
+	// Only one rebuild runs at a time.
+	rebuildMutex sync.Mutex
+}
+
+func newNegativeBloom(size, hashes uint) *negativeBloom {
+	b := &negativeBloom{size: size, hashes: hashes}
+	b.current.Store(newBloom(size, hashes))
+	return b
+}
+
+// add adds a key to the filter, and to the filter being rebuilt if there is one. The store mutex of the key's shard
+// must be held, so a rebuild either sees the key in the store or sees it added here.
+func (b *negativeBloom) add(key string) {
+	// The rebuilding filter is loaded first, as once it's finished it's swapped in as current before being unset.
+	if rebuilding := b.rebuilding.Load(); rebuilding != nil {
+		rebuilding.add(key)
+	}
+	b.current.Load().add(key)
+}
+
+// mayContain checks whether a key might be in cache, a false result means it definitely isn't.
+func (b *negativeBloom) mayContain(key string) bool {
+	return b.current.Load().mayContain(key)
+}
+
+// rebuildBloom replaces the filter with one holding only the keys currently in cache.
+func (h *Hotcache) rebuildBloom() {
+	b := h.bloom
+	b.rebuildMutex.Lock()
+	defer b.rebuildMutex.Unlock()
+
+	atomic.StoreInt64(&b.removed, 0)
+	next := newBloom(b.size, b.hashes)
+	b.rebuilding.Store(next)
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for key := range s.store {
+			next.add(key)
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	b.current.Store(next)
+	b.rebuilding.Store(nil)
+}
+
+// bloomStale checks whether enough keys have been removed that the filter should be rebuilt.
+func (h *Hotcache) bloomStale() bool {
+	return atomic.LoadInt64(&h.bloom.removed) > atomic.LoadInt64(&h.count)
+}
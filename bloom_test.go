@@ -0,0 +1,140 @@
+package hotcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloom(t *testing.T) {
+	b := newBloom(1<<16, 4)
+
+	for i := 0; i < 1000; i++ {
+		b.add(strconv.Itoa(i))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, b.mayContain(strconv.Itoa(i)), true)
+	}
+
+	// False positives are possible, but with this few keys they're rare.
+	positives := 0
+	for i := 1000; i < 2000; i++ {
+		if b.mayContain(strconv.Itoa(i)) {
+			positives++
+		}
+	}
+	assert.Less(t, positives, 10)
+}
+
+func TestNegativeBloomSkipsStore(t *testing.T) {
+	cache := New(WithNegativeBloom(1<<16, 4))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	s := cache.shard("xd2")
+	s.storeMutex.Lock()
+
+	// A key that was never set doesn't need the store lock.
+	done := make(chan struct{})
+	go func() {
+		_, ok := cache.Get("xd2")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, cache.Has("xd2"), false)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lookup of a never set key waited on the store lock")
+	}
+	s.storeMutex.Unlock()
+
+	assert.Equal(t, cache.Stats().Misses, uint64(2))
+}
+
+func TestNegativeBloomReachesStore(t *testing.T) {
+	cache := New(WithNegativeBloom(64, 2))
+	defer cache.Stop()
+
+	// The filter is far too small for this many keys, so it's full of false positives, but every key that was set is
+	// still found.
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, 0)
+	}
+	for i := 0; i < 1000; i++ {
+		val, ok := cache.Get(strconv.Itoa(i))
+		assert.Equal(t, val, i)
+		assert.Equal(t, ok, true)
+	}
+	assert.Equal(t, cache.Has("missing"), false)
+}
+
+func TestNegativeBloomRebuild(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithStartPaused(true), WithNegativeBloom(1<<16, 4))
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, 0)
+	}
+	cache.Set("xd", "xd", 0)
+
+	// Removing fewer keys than are left in cache doesn't rebuild the filter.
+	for i := 0; i < 50; i++ {
+		cache.Delete(strconv.Itoa(i))
+	}
+	cache.tick()
+	assert.Equal(t, cache.bloom.mayContain("0"), true)
+
+	for i := 50; i < 100; i++ {
+		cache.Delete(strconv.Itoa(i))
+	}
+	cache.tick()
+	assert.Equal(t, cache.bloom.mayContain("0"), false)
+	assert.Equal(t, cache.bloom.mayContain("xd"), true)
+
+	// Clear rebuilds the filter too.
+	cache.Clear()
+	assert.Equal(t, cache.bloom.mayContain("xd"), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestNegativeBloomConcurrentRebuild(t *testing.T) {
+	cache := New(WithNegativeBloom(1<<16, 4))
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			cache.rebuildBloom()
+		}
+	}()
+
+	// Keys set while the filter is being rebuilt must never be missed.
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		cache.Set(key, i, 0)
+		assert.Equal(t, cache.Has(key), true)
+	}
+	wg.Wait()
+
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, cache.bloom.mayContain(strconv.Itoa(i)), true)
+	}
+}
+
+func TestWithNegativeBloomInvalid(t *testing.T) {
+	cache := New(WithNegativeBloom(0, 4), WithNegativeBloom(64, 0))
+	defer cache.Stop()
+
+	assert.Nil(t, cache.bloom)
+}
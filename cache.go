@@ -0,0 +1,170 @@
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTypeMismatch is wrapped by the error GetMultiStrict returns for each key holding a value that isn't a V.
+var ErrTypeMismatch = errors.New("hotcache: value has the wrong type")
+
+// Cache is a type-safe wrapper around Hotcache, it stores values of type V against keys of type K so callers don't
+// need to type-assert on every lookup.
+type Cache[K comparable, V any] struct {
+	cache   *Hotcache
+	keyFunc func(K) string
+}
+
+// NewCache creates a new typed cache. Keys are converted to their string form with fmt.Sprint, use
+// NewCacheWithKeyFunc if your key type doesn't have a unique string representation.
+func NewCache[K comparable, V any](opts ...Option) *Cache[K, V] {
+	return NewCacheWithKeyFunc[K, V](defaultKeyFunc[K], opts...)
+}
+
+// NewCacheWithKeyFunc creates a new typed cache that uses keyFunc to convert keys into the strings they're stored
+// under. keyFunc must return a stable and unique string for every key.
+func NewCacheWithKeyFunc[K comparable, V any](keyFunc func(K) string, opts ...Option) *Cache[K, V] {
+	return &Cache[K, V]{
+		cache:   New(opts...),
+		keyFunc: keyFunc,
+	}
+}
+
+// defaultKeyFunc converts a key into a string, skipping fmt entirely for string keys.
+func defaultKeyFunc[K comparable](key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// Stop must be called when you are done with the cache, as it will stop the garbage collecting ticker.
+func (c *Cache[K, V]) Stop() {
+	c.cache.Stop()
+}
+
+// Start restarts a cache that's been stopped, see Hotcache.Start.
+func (c *Cache[K, V]) Start() {
+	c.cache.Start()
+}
+
+// Get retrieves a key that isn't expired from cache, the zero value of V is returned if the key is missing.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	val, ok := c.cache.Get(c.keyFunc(key))
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return c.convert(val)
+}
+
+// GetMulti retrieves every key that isn't expired from cache, keys that are missing are left out of the map. Values
+// stored with a type other than V are left out too, the same as Get treating them as missing.
+func (c *Cache[K, V]) GetMulti(keys []K) map[K]V {
+	names := make([]string, len(keys))
+	byName := make(map[string]K, len(keys))
+	for i, key := range keys {
+		names[i] = c.keyFunc(key)
+		byName[names[i]] = key
+	}
+
+	found := c.cache.GetMulti(names)
+	results := make(map[K]V, len(found))
+	for name, val := range found {
+		if v, ok := c.convert(val); ok {
+			results[byName[name]] = v
+		}
+	}
+	return results
+}
+
+// GetMultiStrict retrieves every key that isn't expired from cache like GetMulti, but rather than hiding values stored
+// with a type other than V it returns the keys holding them, in the order they were passed, along with an error. The
+// error joins one error per mismatched key, each wrapping ErrTypeMismatch, and is nil if every value was a V.
+func (c *Cache[K, V]) GetMultiStrict(keys []K) (map[K]V, []K, error) {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = c.keyFunc(key)
+	}
+
+	found := c.cache.GetMulti(names)
+	results := make(map[K]V, len(found))
+	var mismatched []K
+	var errs []error
+	seen := make(map[string]struct{}, len(found))
+	for i, name := range names {
+		val, ok := found[name]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		v, ok := c.convert(val)
+		if !ok {
+			mismatched = append(mismatched, keys[i])
+			errs = append(errs, fmt.Errorf("%w: %s holds %T", ErrTypeMismatch, name, val))
+			continue
+		}
+		results[keys[i]] = v
+	}
+	return results, mismatched, errors.Join(errs...)
+}
+
+// Set adds a key to store. Use expiration of 0 for no expiry. Note this will override the key if it's existing.
+func (c *Cache[K, V]) Set(key K, value V, expiration time.Duration) {
+	c.cache.Set(c.keyFunc(key), value, expiration)
+}
+
+// Has checks if a key is in cache and not expired
+func (c *Cache[K, V]) Has(key K) bool {
+	return c.cache.Has(c.keyFunc(key))
+}
+
+// Delete removes a key from cache.
+func (c *Cache[K, V]) Delete(key K) {
+	c.cache.Delete(c.keyFunc(key))
+}
+
+// SetNX sets a key only if it doesn't already exist, returning whether it was set.
+func (c *Cache[K, V]) SetNX(key K, value V, expiration time.Duration) bool {
+	return c.cache.SetNX(c.keyFunc(key), value, expiration)
+}
+
+// convert asserts a stored value back into V, see convertValue.
+func (c *Cache[K, V]) convert(val interface{}) (V, bool) {
+	return convertValue[V](val)
+}
+
+// GetTyped retrieves a key from an untyped cache as a T, for type safety without switching to Cache. The zero value of
+// T and false are returned if the key is missing, expired, or holds a value that isn't a T.
+func GetTyped[T any](h *Hotcache, key string) (T, bool) {
+	val, ok := h.Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return convertValue[T](val)
+}
+
+// SetTyped adds a key to an untyped cache, the counterpart to GetTyped, see Hotcache.Set.
+func SetTyped[T any](h *Hotcache, key string, value T, expiration time.Duration) {
+	h.Set(key, value, expiration)
+}
+
+// convertValue asserts a stored value back into V. A nil value is stored when V is an interface type and the caller set
+// nil, so we treat that as the zero value rather than a mismatch.
+func convertValue[V any](val interface{}) (V, bool) {
+	var zero V
+	if val == nil {
+		return zero, true
+	}
+	v, ok := val.(V)
+	if !ok {
+		return zero, false
+	}
+	return v, true
+}
@@ -0,0 +1,189 @@
+package hotcache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheGetNonexistent(t *testing.T) {
+	cache := NewCache[string, int]()
+	defer cache.Stop()
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, 0)
+	assert.Equal(t, ok, false)
+}
+
+func TestCacheGetExists(t *testing.T) {
+	cache := NewCache[string, int]()
+	defer cache.Stop()
+
+	cache.Set("xd", 10, 0)
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, 10)
+	assert.Equal(t, ok, true)
+}
+
+func TestCacheNonStringKeys(t *testing.T) {
+	cache := NewCache[int, string]()
+	defer cache.Stop()
+
+	cache.Set(1, "one", 0)
+	cache.Set(2, "two", 0)
+
+	val, ok := cache.Get(1)
+	assert.Equal(t, val, "one")
+	assert.Equal(t, ok, true)
+
+	assert.Equal(t, cache.Has(2), true)
+	assert.Equal(t, cache.Has(3), false)
+}
+
+func TestCacheKeyFunc(t *testing.T) {
+	type userKey struct {
+		id int
+	}
+
+	cache := NewCacheWithKeyFunc[userKey, string](func(k userKey) string {
+		return "user:" + strconv.Itoa(k.id)
+	})
+	defer cache.Stop()
+
+	cache.Set(userKey{id: 1}, "xd", 0)
+
+	val, ok := cache.Get(userKey{id: 1})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	raw, ok := cache.cache.Get("user:1")
+	assert.Equal(t, raw, "xd")
+	assert.Equal(t, ok, true)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewCache[string, string](WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, cache.Has("xd"), true)
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "")
+	assert.Equal(t, ok, false)
+}
+
+func TestCacheGetMulti(t *testing.T) {
+	cache := NewCache[int, string]()
+	defer cache.Stop()
+
+	cache.Set(1, "xd", 0)
+	cache.Set(2, "xd2", 0)
+
+	assert.Equal(t, cache.GetMulti([]int{1, 2, 3}), map[int]string{1: "xd", 2: "xd2"})
+	assert.Equal(t, cache.GetMulti(nil), map[int]string{})
+}
+
+func TestCacheGetMultiWrongType(t *testing.T) {
+	cache := NewCache[string, string]()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.cache.Set("xd2", 10, 0)
+
+	assert.Equal(t, cache.GetMulti([]string{"xd", "xd2"}), map[string]string{"xd": "xd"})
+}
+
+func TestCacheGetMultiStrict(t *testing.T) {
+	cache := NewCache[string, string]()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.cache.Set("xd2", 10, 0)
+	cache.cache.Set("xd3", []byte("xd"), 0)
+
+	results, mismatched, err := cache.GetMultiStrict([]string{"xd", "xd3", "xd2", "missing", "xd2"})
+	assert.Equal(t, results, map[string]string{"xd": "xd"})
+	assert.Equal(t, mismatched, []string{"xd3", "xd2"})
+	assert.Equal(t, errors.Is(err, ErrTypeMismatch), true)
+	assert.EqualError(t, err, "hotcache: value has the wrong type: xd3 holds []uint8\nhotcache: value has the wrong type: xd2 holds int")
+
+	results, mismatched, err = cache.GetMultiStrict([]string{"xd", "missing"})
+	assert.Equal(t, results, map[string]string{"xd": "xd"})
+	assert.Nil(t, mismatched)
+	assert.Nil(t, err)
+}
+
+func TestCacheDelete(t *testing.T) {
+	cache := NewCache[string, string]()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Delete("xd")
+
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestCacheSetNX(t *testing.T) {
+	cache := NewCache[string, string]()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetNX("xd", "xd", 0), true)
+	assert.Equal(t, cache.SetNX("xd", "xd2", 0), false)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+}
+
+func TestCacheNilInterfaceValue(t *testing.T) {
+	cache := NewCache[string, error]()
+	defer cache.Stop()
+
+	cache.Set("xd", nil, 0)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, true)
+}
+
+func TestGetTyped(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	SetTyped(cache, "xd", 5, 0)
+	SetTyped(cache, "xd2", []string{"xd"}, 0)
+
+	val, ok := GetTyped[int](cache, "xd")
+	assert.Equal(t, val, 5)
+	assert.Equal(t, ok, true)
+
+	list, ok := GetTyped[[]string](cache, "xd2")
+	assert.Equal(t, list, []string{"xd"})
+	assert.Equal(t, ok, true)
+
+	val, ok = GetTyped[int](cache, "xd3")
+	assert.Equal(t, val, 0)
+	assert.Equal(t, ok, false)
+}
+
+func TestGetTypedWrongType(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	val, ok := GetTyped[int](cache, "xd")
+	assert.Equal(t, val, 0)
+	assert.Equal(t, ok, false)
+
+	// The value is left alone.
+	str, ok := GetTyped[string](cache, "xd")
+	assert.Equal(t, str, "xd")
+	assert.Equal(t, ok, true)
+}
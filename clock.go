@@ -0,0 +1,15 @@
+package hotcache
+
+import "time"
+
+// Clock tells the cache the current time, see WithClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock, using the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
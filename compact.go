@@ -0,0 +1,37 @@
+package hotcache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// compact shrinks every shard's list of expiring keys that has far more room than it needs, see WithCompactionInterval.
+func (h *Hotcache) compact() {
+	compacted := 0
+	for _, s := range h.shards {
+		if s.compactExpiry() {
+			compacted++
+		}
+	}
+
+	if h.debugEnabled() {
+		h.debug("hotcache: compacted expiring keys", slog.Int("shards", compacted))
+	}
+}
+
+// compactor compacts the cache every interval from WithCompactionInterval until done is closed.
+func (h *Hotcache) compactor(done <-chan struct{}) {
+	defer h.background.Done()
+
+	ticker := time.NewTicker(h.options.compactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.compact()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package hotcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// expiringKeyRoom returns the total capacity of every shard's list of expiring keys.
+func expiringKeyRoom(h *Hotcache) int {
+	room := 0
+	for _, s := range h.shards {
+		s.expiryMutex.RLock()
+		room += cap(s.expiringKeys)
+		s.expiryMutex.RUnlock()
+	}
+	return room
+}
+
+func TestCompact(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithShards(1))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Millisecond*10)
+	}
+	for i := 0; i < 10; i++ {
+		cache.Set("long"+strconv.Itoa(i), i, time.Hour)
+	}
+
+	// Reading the expired keys evicts them, but the list keeps its room.
+	clock.Advance(time.Millisecond * 10)
+	for i := 0; i < 1000; i++ {
+		cache.Get(strconv.Itoa(i))
+	}
+	assert.Equal(t, expiringKeyCount(cache), 10)
+	assert.True(t, expiringKeyRoom(cache) >= 1010)
+
+	cache.compact()
+	assert.Equal(t, expiringKeyCount(cache), 10)
+	assert.Equal(t, expiringKeyRoom(cache), 10)
+	assertExpiryTracked(t, cache)
+
+	// Compacted keys still expire.
+	clock.Advance(time.Hour)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestCompactSkipsFittingLists(t *testing.T) {
+	cache := New(WithShards(1))
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+
+	room := expiringKeyRoom(cache)
+	assert.Equal(t, cache.shards[0].compactExpiry(), false)
+	assert.Equal(t, expiringKeyRoom(cache), room)
+}
+
+func TestCompactKeepsCapacityHint(t *testing.T) {
+	cache := New(WithShards(1), WithCapacityHint(100))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	for i := 0; i < 990; i++ {
+		cache.Delete(strconv.Itoa(i))
+	}
+
+	cache.compact()
+	assert.Equal(t, expiringKeyRoom(cache), 100)
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithCompactionInterval(t *testing.T) {
+	cache := New(WithShards(1), WithCompactionInterval(time.Millisecond))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Delete(strconv.Itoa(i))
+	}
+
+	eventually(t, func() bool {
+		return expiringKeyRoom(cache) == 0
+	})
+}
+
+func TestWithCompactionIntervalInvalid(t *testing.T) {
+	cache := New(WithCompactionInterval(0), WithCompactionInterval(-1))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.compactionInterval, time.Duration(0))
+}
@@ -0,0 +1,199 @@
+package hotcache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// call is an in-flight compute for a key, waiters block on done until value and err are populated.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// Number of callers waiting on the result, once they've all given up the compute's context is cancelled.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// detachedContext keeps the values of its parent but not its cancellation, so a compute shared between callers isn't
+// cancelled just because the caller that started it gave up.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// GetOrCompute returns the value of a key if it exists, otherwise fn is called and its result is cached with the given
+// expiration. Concurrent callers that miss on the same key share a single call to fn and all receive its result.
+// Errors returned by fn aren't cached and are returned to every waiter.
+func (h *Hotcache) GetOrCompute(key string, expiration time.Duration, fn func() (interface{}, error)) (interface{}, error) {
+	return h.GetWithContext(context.Background(), key, expiration, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// GetWithContext is GetOrCompute with cancellation, if ctx is done before the value is computed ctx.Err() is returned.
+//
+// Concurrent callers share a single call to fn as with GetOrCompute, each waiting only as long as their own context
+// allows. The context passed to fn carries the values of the caller that started it, and is only cancelled once every
+// caller waiting on it has given up.
+func (h *Hotcache) GetWithContext(ctx context.Context, key string, expiration time.Duration, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return h.getOrLoad(ctx, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		value, err := fn(ctx)
+		return value, expiration, err
+	})
+}
+
+// loadFunc loads the value of a missing key along with the expiration to cache it with, see getOrLoad.
+type loadFunc func(ctx context.Context) (interface{}, time.Duration, error)
+
+// getOrLoad is GetWithContext with the expiration returned by fn rather than fixed up front.
+func (h *Hotcache) getOrLoad(ctx context.Context, key string, fn loadFunc) (interface{}, error) {
+	if val, ok := h.Get(key); ok {
+		return val, nil
+	}
+
+	h.callMutex.Lock()
+	c, ok := h.calls[key]
+	if !ok {
+		// Another caller may have finished computing the key between our lookup and obtaining the lock.
+		if val, ok := h.Get(key); ok {
+			h.callMutex.Unlock()
+			return val, nil
+		}
+
+		var computeCtx context.Context
+		var cancel context.CancelFunc
+		if h.options.loaderTimeout > 0 {
+			computeCtx, cancel = context.WithTimeout(detachedContext{ctx}, h.options.loaderTimeout)
+		} else {
+			computeCtx, cancel = context.WithCancel(detachedContext{ctx})
+		}
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		h.calls[key] = c
+		go h.compute(computeCtx, key, c, fn)
+	}
+	c.waiters++
+	h.callMutex.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		h.callMutex.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// Nobody is waiting on the result anymore, later callers start a fresh compute rather than joining a
+			// cancelled one.
+			c.cancel()
+			if h.calls[key] == c {
+				delete(h.calls, key)
+			}
+		}
+		h.callMutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// compute runs fn for a call, caching its result if it succeeds and then releasing every waiter.
+func (h *Hotcache) compute(ctx context.Context, key string, c *call, fn loadFunc) {
+	var value interface{}
+	var expiration time.Duration
+	var err error
+	if h.options.loaderTimeout > 0 {
+		value, expiration, err = h.computeWithTimeout(ctx, fn)
+	} else {
+		value, expiration, err = fn(ctx)
+	}
+
+	if err == nil {
+		h.Set(key, value, expiration)
+	} else if h.debugEnabled() {
+		h.debug("hotcache: compute failed", slog.String("key", key), slog.Any("error", err))
+	}
+
+	h.callMutex.Lock()
+	if h.calls[key] == c {
+		delete(h.calls, key)
+	}
+	c.value, c.err = value, err
+	h.callMutex.Unlock()
+
+	c.cancel()
+	close(c.done)
+}
+
+// computeWithTimeout runs fn for a call, giving up on it once ctx is done rather than waiting for it to return, so a
+// loader that ignores its context can't hold up waiters past WithLoaderTimeout. A result it returns after that is
+// discarded.
+func (h *Hotcache) computeWithTimeout(ctx context.Context, fn loadFunc) (interface{}, time.Duration, error) {
+	type result struct {
+		value      interface{}
+		expiration time.Duration
+		err        error
+	}
+
+	// Buffered so fn's goroutine can exit once it returns, even though nothing's receiving anymore.
+	done := make(chan result, 1)
+	go func() {
+		value, expiration, err := fn(ctx)
+		done <- result{value: value, expiration: expiration, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.expiration, r.err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// GetOrComputeMulti returns every key that exists, and loads the rest with a single call to loader, caching the values
+// it returns with the given expiration. loader is only passed the keys that are missing or expired, once each. Keys it
+// leaves out of its result are treated as not found, so they're left out of the result and aren't cached, as are any
+// keys that weren't asked for. If loader returns an error nothing is cached and the error is returned.
+//
+// Unlike GetOrCompute, concurrent callers don't share calls to loader, as their batches of missing keys rarely match.
+func (h *Hotcache) GetOrComputeMulti(keys []string, expiration time.Duration, loader func(missing []string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	results := h.GetMulti(keys)
+
+	var missing []string
+	seen := make(map[string]struct{}, len(keys)-len(results))
+	for _, key := range keys {
+		if _, ok := results[key]; ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		missing = append(missing, key)
+	}
+
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	loaded, err := loader(missing)
+	if err != nil {
+		if h.debugEnabled() {
+			h.debug("hotcache: batch load failed", slog.Int("keys", len(missing)), slog.Any("error", err))
+		}
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(loaded))
+	for _, key := range missing {
+		if val, ok := loaded[key]; ok {
+			values[key] = val
+			results[key] = val
+		}
+	}
+	h.SetMulti(values, expiration)
+
+	return results, nil
+}
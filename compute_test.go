@@ -0,0 +1,325 @@
+package hotcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrCompute(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	val, err := cache.GetOrCompute("xd", 0, func() (interface{}, error) {
+		return "xd", nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+
+	val, err = cache.GetOrCompute("xd", 0, func() (interface{}, error) {
+		t.Error("fn should not be called on a hit")
+		return nil, nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+}
+
+func TestGetOrComputeError(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	computeErr := errors.New("backend down")
+
+	val, err := cache.GetOrCompute("xd", 0, func() (interface{}, error) {
+		return nil, computeErr
+	})
+	assert.Equal(t, val, nil)
+	assert.Equal(t, err, computeErr)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestGetOrComputeDedup(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "xd", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.GetOrCompute("xd", time.Second, fn)
+			assert.Equal(t, val, "xd")
+			assert.Equal(t, err, nil)
+		}()
+	}
+
+	// Give every goroutine a chance to start waiting on the in-flight call.
+	time.Sleep(time.Millisecond * 20)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestGetOrComputeDedupError(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	computeErr := errors.New("backend down")
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return nil, computeErr
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetOrCompute("xd", time.Second, fn)
+			assert.Equal(t, err, computeErr)
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	close(release)
+	wg.Wait()
+}
+
+func TestGetWithContext(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "xd")
+
+	val, err := cache.GetWithContext(ctx, "xd", 0, func(ctx context.Context) (interface{}, error) {
+		// Values from the caller's context are passed through.
+		return ctx.Value(ctxKey{}), nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+
+	val, err = cache.GetWithContext(ctx, "xd", 0, func(ctx context.Context) (interface{}, error) {
+		t.Error("fn should not be called on a hit")
+		return nil, nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+}
+
+func TestGetWithContextCancelledWaiter(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "xd", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledResult := make(chan error, 1)
+	go func() {
+		_, err := cache.GetWithContext(cancelledCtx, "xd", 0, fn)
+		cancelledResult <- err
+	}()
+	<-started
+
+	succeededResult := make(chan interface{}, 1)
+	go func() {
+		val, _ := cache.GetWithContext(context.Background(), "xd", 0, fn)
+		succeededResult <- val
+	}()
+
+	// Let the second caller join the in-flight call before the first gives up.
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+	assert.Equal(t, <-cancelledResult, context.Canceled)
+
+	// The remaining waiter keeps the compute alive.
+	close(release)
+	assert.Equal(t, <-succeededResult, "xd")
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestGetWithContextAllWaitersCancelled(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	computeErr := make(chan error, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	_, err := cache.GetWithContext(ctx, "xd", 0, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		computeErr <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	// Once nobody is waiting the compute's context is cancelled too.
+	assert.Equal(t, <-computeErr, context.Canceled)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	// Later callers start a fresh compute.
+	val, err := cache.GetWithContext(context.Background(), "xd", 0, func(ctx context.Context) (interface{}, error) {
+		return "xd", nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+}
+
+func TestLoaderTimeout(t *testing.T) {
+	cache := New(WithLoaderTimeout(time.Millisecond * 100))
+	defer cache.Stop()
+
+	var calls int32
+	release := make(chan struct{})
+	returned := make(chan struct{}, 10)
+	fn := func() (interface{}, error) {
+		defer func() { returned <- struct{}{} }()
+		atomic.AddInt32(&calls, 1)
+		// Ignores its context, as hanging loaders tend to.
+		<-release
+		return "late", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.GetOrCompute("xd", time.Second, fn)
+			assert.Equal(t, val, nil)
+			assert.Equal(t, err, context.DeadlineExceeded)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// The loader's late result isn't cached.
+	close(release)
+	<-returned
+	assert.Equal(t, cache.Has("xd"), false)
+
+	// Later callers start a fresh load.
+	val, err := cache.GetOrCompute("xd", time.Second, func() (interface{}, error) {
+		return "xd", nil
+	})
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestLoaderTimeoutContext(t *testing.T) {
+	cache := New(WithLoaderTimeout(time.Millisecond * 20))
+	defer cache.Stop()
+
+	// The loader's context is cancelled once the timeout passes.
+	cancelled := make(chan error, 1)
+	_, err := cache.GetWithContext(context.Background(), "xd", time.Second, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, <-cancelled, context.DeadlineExceeded)
+
+	// Callers with shorter deadlines of their own still give up first.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = cache.GetWithContext(ctx, "xd2", time.Second, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func TestGetOrComputeMulti(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", time.Second)
+	clock.Advance(time.Second)
+
+	var calls [][]string
+	loader := func(missing []string) (map[string]interface{}, error) {
+		calls = append(calls, missing)
+		return map[string]interface{}{
+			"xd2":   "loaded",
+			"xd3":   "loaded",
+			"other": "loaded",
+		}, nil
+	}
+
+	vals, err := cache.GetOrComputeMulti([]string{"xd", "xd2", "xd3", "xd4", "xd3"}, time.Second, loader)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, vals, map[string]interface{}{"xd": "xd", "xd2": "loaded", "xd3": "loaded"})
+
+	// loader is only passed the missing keys, and keys it leaves out or that weren't asked for aren't cached.
+	assert.Equal(t, calls, [][]string{{"xd2", "xd3", "xd4"}})
+	assert.Equal(t, cache.Has("xd4"), false)
+	assert.Equal(t, cache.Has("other"), false)
+
+	ttl, _ := cache.TTL("xd3")
+	assert.Equal(t, ttl, time.Second)
+
+	// Everything but xd4 is cached now.
+	calls = nil
+	_, err = cache.GetOrComputeMulti([]string{"xd", "xd2", "xd3", "xd4"}, time.Second, loader)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, calls, [][]string{{"xd4"}})
+}
+
+func TestGetOrComputeMultiHits(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	vals, err := cache.GetOrComputeMulti([]string{"xd"}, 0, func([]string) (map[string]interface{}, error) {
+		t.Error("loader should not be called when every key hits")
+		return nil, nil
+	})
+	assert.Equal(t, vals, map[string]interface{}{"xd": "xd"})
+	assert.Equal(t, err, nil)
+}
+
+func TestGetOrComputeMultiError(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	loadErr := errors.New("backend down")
+
+	vals, err := cache.GetOrComputeMulti([]string{"xd", "xd2"}, 0, func([]string) (map[string]interface{}, error) {
+		return map[string]interface{}{"xd2": "xd2"}, loadErr
+	})
+	assert.Nil(t, vals)
+	assert.Equal(t, err, loadErr)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
@@ -0,0 +1,102 @@
+package hotcache
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrNotInt64 is returned by Increment and Decrement when the key holds a value that isn't an int64.
+var ErrNotInt64 = errors.New("hotcache: value is not an int64")
+
+// ErrNotFloat64 is returned by IncrementFloat when the key holds a value that isn't a float64.
+var ErrNotFloat64 = errors.New("hotcache: value is not a float64")
+
+// Increment adds delta to the int64 stored at key and returns the new total. Missing keys are treated as 0 and are
+// created without an expiry, existing keys keep their expiry. Totals wrap around on overflow.
+func (h *Hotcache) Increment(key string, delta int64) (int64, error) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	return h.increment(s, key, delta)
+}
+
+// increment is Increment assuming the store mutex is held.
+func (h *Hotcache) increment(s *shard, key string, delta int64) (int64, error) {
+	return addNumber(h, s, key, delta, ErrNotInt64)
+}
+
+// Decrement subtracts delta from the int64 stored at key and returns the new total, see Increment.
+func (h *Hotcache) Decrement(key string, delta int64) (int64, error) {
+	return h.Increment(key, -delta)
+}
+
+// IncrementFloat adds delta to the float64 stored at key and returns the new total, such as for accumulating latency
+// sums. Like Increment, missing keys are treated as 0 and are created without an expiry, and existing keys keep their
+// expiry. Use a negative delta to subtract.
+func (h *Hotcache) IncrementFloat(key string, delta float64) (float64, error) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	return addNumber(h, s, key, delta, ErrNotFloat64)
+}
+
+// Number is the constraint for the values IncrementT can add to, every integer and floating point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// IncrementT adds delta to the V stored at key in a typed cache and returns the new total, like Increment but for any
+// numeric V and without asserting on the result. Missing keys are treated as 0 and are created without an expiry,
+// existing keys keep their expiry. ErrTypeMismatch is returned if the key holds a value that isn't a V, such as one
+// set through the underlying cache. Integer totals wrap around on overflow.
+func IncrementT[K comparable, V Number](c *Cache[K, V], key K, delta V) (V, error) {
+	h := c.cache
+	k := c.keyFunc(key)
+	s := h.shard(k)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	return addNumber(h, s, k, delta, ErrTypeMismatch)
+}
+
+// addNumber adds delta to the V stored at key, returning errWrongType if it holds anything else. It's the body of every
+// increment, and assumes the store mutex is held.
+func addNumber[V Number](h *Hotcache, s *shard, key string, delta V, errWrongType error) (V, error) {
+	old, exists := s.store[key]
+	if !exists || !old.live(h.now()) {
+		h.set(s, key, delta, NoExpiry)
+		return delta, nil
+	}
+
+	current, ok := old.value.(V)
+	if !ok {
+		var zero V
+		return zero, errWrongType
+	}
+
+	total := current + delta
+	h.replaceValue(s, key, old, total)
+	return total, nil
+}
+
+// replaceValue swaps the value of an existing key while keeping its expiry, or restarting its TTL with
+// WithExtendTTLOnWrite. Assumes the store mutex is held.
+func (h *Hotcache) replaceValue(s *shard, key string, old *cacheValue, value interface{}) {
+	var updated *cacheValue
+	if h.options.extendTTLOnWrite && !old.expiry.IsZero() && old.ttl > 0 {
+		updated = h.restarted(old)
+	} else {
+		updated = old.copy()
+	}
+	updated.value = value
+	h.update(s, key, old, updated)
+	atomic.AddUint64(&h.stats.sets, 1)
+	h.recordEvent(s, key, EventSet, value)
+}
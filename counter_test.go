@@ -0,0 +1,288 @@
+package hotcache
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	total, err := cache.Increment("xd", 5)
+	assert.Equal(t, total, int64(5))
+	assert.Equal(t, err, nil)
+
+	total, err = cache.Increment("xd", 2)
+	assert.Equal(t, total, int64(7))
+	assert.Equal(t, err, nil)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, int64(7))
+	assert.Equal(t, ok, true)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+}
+
+func TestDecrement(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	total, err := cache.Decrement("xd", 3)
+	assert.Equal(t, total, int64(-3))
+	assert.Equal(t, err, nil)
+
+	total, err = cache.Decrement("xd", -10)
+	assert.Equal(t, total, int64(7))
+	assert.Equal(t, err, nil)
+}
+
+func TestIncrementKeepsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", int64(1), time.Millisecond*10)
+
+	total, err := cache.Increment("xd", 1)
+	assert.Equal(t, total, int64(2))
+	assert.Equal(t, err, nil)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Has("xd"), false)
+
+	// Expired keys start counting from 0 again.
+	total, err = cache.Increment("xd", 1)
+	assert.Equal(t, total, int64(1))
+	assert.Equal(t, err, nil)
+}
+
+func TestIncrementWraparound(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", int64(math.MaxInt64), 0)
+
+	total, err := cache.Increment("xd", 1)
+	assert.Equal(t, total, int64(math.MinInt64))
+	assert.Equal(t, err, nil)
+
+	total, err = cache.Decrement("xd", 1)
+	assert.Equal(t, total, int64(math.MaxInt64))
+	assert.Equal(t, err, nil)
+}
+
+func TestIncrementTypeMismatch(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", 1, 0)
+
+	total, err := cache.Increment("xd", 1)
+	assert.Equal(t, total, int64(0))
+	assert.Equal(t, err, ErrNotInt64)
+
+	_, err = cache.Decrement("xd2", 1)
+	assert.Equal(t, err, ErrNotInt64)
+
+	// The stored values are left untouched.
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+}
+
+func TestIncrementFloat(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	total, err := cache.IncrementFloat("xd", 1.5)
+	assert.Equal(t, total, 1.5)
+	assert.Equal(t, err, nil)
+
+	total, err = cache.IncrementFloat("xd", 0.25)
+	assert.Equal(t, total, 1.75)
+	assert.Equal(t, err, nil)
+
+	total, err = cache.IncrementFloat("xd", -2)
+	assert.Equal(t, total, -0.25)
+	assert.Equal(t, err, nil)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, -0.25)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+
+	// Existing keys keep their expiry.
+	cache.Set("xd2", 1.0, time.Millisecond*10)
+	total, _ = cache.IncrementFloat("xd2", 1)
+	assert.Equal(t, total, 2.0)
+	ttl, _ = cache.TTL("xd2")
+	assert.Equal(t, ttl, time.Millisecond*10)
+}
+
+func TestIncrementFloatTypeMismatch(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", int64(1), 0)
+
+	total, err := cache.IncrementFloat("xd", 1)
+	assert.Equal(t, total, 0.0)
+	assert.Equal(t, err, ErrNotFloat64)
+
+	// float64 counters aren't int64 counters either.
+	cache.IncrementFloat("xd2", 1)
+	_, err = cache.Increment("xd2", 1)
+	assert.Equal(t, err, ErrNotInt64)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, int64(1))
+}
+
+func TestIncrementT(t *testing.T) {
+	ints := NewCache[string, int]()
+	defer ints.Stop()
+
+	total, err := IncrementT(ints, "xd", 5)
+	assert.Equal(t, total, 5)
+	assert.Nil(t, err)
+
+	total, err = IncrementT(ints, "xd", -2)
+	assert.Equal(t, total, 3)
+	assert.Nil(t, err)
+
+	val, _ := ints.Get("xd")
+	assert.Equal(t, val, 3)
+
+	int64s := NewCache[int, int64]()
+	defer int64s.Stop()
+
+	total64, err := IncrementT(int64s, 1, math.MaxInt64)
+	assert.Equal(t, total64, int64(math.MaxInt64))
+	assert.Nil(t, err)
+
+	total64, _ = IncrementT(int64s, 1, 1)
+	assert.Equal(t, total64, int64(math.MinInt64))
+
+	floats := NewCache[string, float64]()
+	defer floats.Stop()
+
+	totalFloat, err := IncrementT(floats, "latency", 1.5)
+	assert.Equal(t, totalFloat, 1.5)
+	assert.Nil(t, err)
+
+	totalFloat, _ = IncrementT(floats, "latency", 0.25)
+	assert.Equal(t, totalFloat, 1.75)
+}
+
+func TestIncrementTKeepsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewCache[string, int](WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", 1, time.Second)
+	clock.Advance(time.Millisecond * 500)
+
+	total, _ := IncrementT(cache, "xd", 1)
+	assert.Equal(t, total, 2)
+
+	ttl, _ := cache.cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*500)
+
+	// Once expired the total starts again from 0, without an expiry.
+	clock.Advance(time.Millisecond * 500)
+	total, _ = IncrementT(cache, "xd", 1)
+	assert.Equal(t, total, 1)
+
+	ttl, _ = cache.cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache.cache)
+}
+
+func TestIncrementTTypeMismatch(t *testing.T) {
+	cache := NewCache[string, int]()
+	defer cache.Stop()
+
+	cache.cache.Set("xd", int64(1), 0)
+
+	total, err := IncrementT(cache, "xd", 1)
+	assert.Equal(t, total, 0)
+	assert.Equal(t, err, ErrTypeMismatch)
+
+	val, _ := cache.cache.Get("xd")
+	assert.Equal(t, val, int64(1))
+}
+
+func TestExtendTTLOnWrite(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithExtendTTLOnWrite(true))
+	defer cache.Stop()
+
+	cache.Set("xd", int64(0), time.Second)
+
+	// Each write pushes the expiry back, so the key outlives its TTL.
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Millisecond * 600)
+		_, err := cache.Increment("xd", 1)
+		assert.Equal(t, err, nil)
+	}
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, int64(5))
+	assert.Equal(t, ok, true)
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Second)
+
+	// It expires once writes stop.
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	// Other in place updates extend it too.
+	cache.LPush("list", "xd")
+	cache.Expire("list", time.Second)
+	clock.Advance(time.Millisecond * 600)
+	cache.LPush("list", "xd2")
+	ttl, _ = cache.TTL("list")
+	assert.Equal(t, ttl, time.Second)
+	assertExpiryTracked(t, cache)
+}
+
+func TestExtendTTLOnWriteWithoutExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithExtendTTLOnWrite(true))
+	defer cache.Stop()
+
+	// Keys without an expiry don't gain one.
+	cache.Increment("xd", 1)
+	cache.Increment("xd", 1)
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+
+	// Setting with 0 still removes the expiry.
+	cache.Set("xd2", int64(1), time.Second)
+	cache.Set("xd2", int64(1), 0)
+	cache.Increment("xd2", 1)
+	ttl, _ = cache.TTL("xd2")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache)
+}
+
+func TestIncrementKeepsExpiryByDefault(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", int64(0), time.Second)
+	clock.Advance(time.Millisecond * 600)
+	cache.Increment("xd", 1)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*400)
+}
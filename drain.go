@@ -0,0 +1,63 @@
+package hotcache
+
+// DrainTo moves every key that hasn't expired into dst along with when it expires, leaving this cache empty, and
+// returns the number of keys moved. Expired keys and negative entries from SetMissing are dropped rather than moved.
+//
+// Every shard of both caches is locked while keys are moved, so other callers never see a key in both caches or in
+// neither. The caches are always locked in the order they were created in, so two caches draining into each other at
+// once can't deadlock. Subscribers to this cache see each moved key as deleted, and subscribers to dst see it as set.
+func (h *Hotcache) DrainTo(dst *Hotcache) int {
+	if dst == h {
+		return 0
+	}
+
+	first, second := h, dst
+	if first.id > second.id {
+		first, second = second, first
+	}
+	first.lockAll()
+	second.lockAll()
+
+	now := h.now()
+	moved := 0
+	for _, s := range h.shards {
+		// Expiring keys are reset first so removing each key doesn't have to find it in the list, as with Clear.
+		s.resetExpiry()
+		for key, val := range s.store {
+			if !val.live(now) {
+				reason := ReasonFlushed
+				if val.expired(now) {
+					reason = ReasonExpired
+				}
+				h.remove(s, key, val, reason)
+				continue
+			}
+
+			h.detach(s, key, val)
+			h.recordEvent(s, key, EventDeleted, val.value)
+
+			// dst counts uses separately, so it doesn't share access counts with this cache.
+			copied := val.copy()
+			copied.access = nil
+			dst.setValue(dst.shard(key), key, copied)
+			moved++
+		}
+		s.store = make(map[string]*cacheValue, s.capacity)
+	}
+
+	evictions, events := h.unlockAll()
+	dstEvictions, dstEvents := dst.unlockAll()
+	h.afterUnlock(evictions, events)
+	dst.afterUnlock(dstEvictions, dstEvents)
+	return moved
+}
+
+// lockAll obtains the store mutex of every shard, see lockShards.
+func (h *Hotcache) lockAll() {
+	h.lockShards(h.shards)
+}
+
+// unlockAll releases the store mutexes obtained by lockAll, see unlockShards.
+func (h *Hotcache) unlockAll() ([]eviction, []Event) {
+	return h.unlockShards(h.shards)
+}
@@ -0,0 +1,118 @@
+package hotcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainTo(t *testing.T) {
+	clock := newFakeClock()
+	src := New(WithClock(clock))
+	defer src.Stop()
+	dst := New(WithClock(clock), WithShards(3))
+	defer dst.Stop()
+
+	src.Set("xd", "xd", 0)
+	src.Set("xd2", "xd2", time.Second*2)
+	src.Set("expired", "xd", time.Second)
+	src.SetMissing("missing", 0)
+	dst.Set("xd3", "xd3", 0)
+	clock.Advance(time.Second)
+
+	assert.Equal(t, src.DrainTo(dst), 2)
+
+	assert.Equal(t, src.LenApprox(), 0)
+	assert.Equal(t, expiringKeyCount(src), 0)
+	assert.ElementsMatch(t, dst.Keys(), []string{"xd", "xd2", "xd3"})
+
+	// Remaining TTLs carry over.
+	ttl, _ := dst.TTL("xd2")
+	assert.Equal(t, ttl, time.Second)
+	ttl, _ = dst.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, dst)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, dst.Has("xd2"), false)
+
+	// The source is still usable.
+	src.Set("xd", "xd", 0)
+	assert.Equal(t, src.Has("xd"), true)
+}
+
+func TestDrainToSelf(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.DrainTo(cache), 0)
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestDrainToEvents(t *testing.T) {
+	var evicted []string
+	src := New()
+	defer src.Stop()
+	dst := New(WithMaxKeys(1), WithOnEvict(func(key string, _ interface{}, _ EvictReason) {
+		evicted = append(evicted, key)
+	}))
+	defer dst.Stop()
+
+	srcEvents, unsubscribe := src.Subscribe()
+	dst.Set("xd", "xd", 0)
+	src.Set("xd2", "xd2", 0)
+
+	dstEvents, unsubscribeDst := dst.Subscribe()
+	assert.Equal(t, src.DrainTo(dst), 1)
+	unsubscribe()
+	unsubscribeDst()
+
+	assert.Equal(t, drainEvents(srcEvents), []Event{
+		{Key: "xd2", Type: EventSet, Value: "xd2"},
+		{Key: "xd2", Type: EventDeleted, Value: "xd2"},
+	})
+	assert.Equal(t, drainEvents(dstEvents), []Event{
+		{Key: "xd2", Type: EventSet, Value: "xd2"},
+		{Key: "xd", Type: EventEvicted, Value: "xd"},
+	})
+
+	// The destination's bounds are enforced once the keys have moved.
+	assert.Equal(t, evicted, []string{"xd"})
+	assert.Equal(t, dst.Keys(), []string{"xd2"})
+}
+
+func TestDrainToConcurrent(t *testing.T) {
+	a := New()
+	defer a.Stop()
+	b := New()
+	defer b.Stop()
+
+	for i := 0; i < 100; i++ {
+		a.Set(strconv.Itoa(i), i, 0)
+	}
+
+	// Draining both ways at once mustn't deadlock, and no keys are lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				a.DrainTo(b)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				b.DrainTo(a)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, a.Len()+b.Len(), 100)
+}
@@ -0,0 +1,48 @@
+package hotcache
+
+import "time"
+
+// Entry is a copy of a key's value and metadata, returned by GetEntry. Holding on to it doesn't keep anything in cache
+// alive, and it isn't updated if the key changes.
+type Entry struct {
+	// Value is the key's value.
+	Value interface{}
+	// ExpiresAt is when the key expires, the zero time if it doesn't have an expiry.
+	ExpiresAt time.Time
+
+	// The cache's clock, so TTL is measured the same way the cache measures expiry.
+	clock Clock
+}
+
+// IsExpiring reports whether the key has an expiry.
+func (e *Entry) IsExpiring() bool {
+	return !e.ExpiresAt.IsZero()
+}
+
+// TTL returns how long is left until the key expires, this is calculated each time it's called so it counts down
+// while the Entry is held. NoExpiry is returned if the key doesn't have an expiry, and 0 once it's expired.
+func (e *Entry) TTL() time.Duration {
+	if !e.IsExpiring() {
+		return NoExpiry
+	}
+
+	remaining := e.ExpiresAt.Sub(e.clock.Now())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// GetEntry retrieves a key that isn't expired from cache like Get, returning a copy of its value and metadata.
+func (h *Hotcache) GetEntry(key string) (*Entry, bool) {
+	val, ok, _ := h.lookupValue(key)
+	if !ok {
+		return nil, false
+	}
+
+	return &Entry{
+		Value:     val.value,
+		ExpiresAt: val.expiry,
+		clock:     h.options.clock,
+	}, true
+}
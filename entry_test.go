@@ -0,0 +1,65 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEntry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	entry, ok := cache.GetEntry("xd")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, entry.Value, "xd")
+	assert.Equal(t, entry.ExpiresAt, time.Time{})
+	assert.Equal(t, entry.IsExpiring(), false)
+	assert.Equal(t, entry.TTL(), NoExpiry)
+
+	// The entry is a copy, so it isn't affected by later changes.
+	cache.Set("xd", "xd2", 0)
+	assert.Equal(t, entry.Value, "xd")
+}
+
+func TestGetEntryExpiring(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+
+	entry, ok := cache.GetEntry("xd")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, entry.Value, "xd")
+	assert.Equal(t, entry.ExpiresAt, clock.Now().Add(time.Second))
+	assert.Equal(t, entry.IsExpiring(), true)
+	assert.Equal(t, entry.TTL(), time.Second)
+
+	clock.Advance(time.Millisecond * 400)
+	assert.Equal(t, entry.TTL(), time.Millisecond*600)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, entry.TTL(), time.Duration(0))
+
+	entry, ok = cache.GetEntry("xd")
+	assert.Nil(t, entry)
+	assert.Equal(t, ok, false)
+}
+
+func TestGetEntryMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	entry, ok := cache.GetEntry("xd")
+	assert.Nil(t, entry)
+	assert.Equal(t, ok, false)
+
+	cache.SetMissing("xd", 0)
+	_, ok = cache.GetEntry("xd")
+	assert.Equal(t, ok, false)
+}
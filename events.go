@@ -0,0 +1,180 @@
+package hotcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// eventBufferSize is the number of events each subscriber can fall behind by before its events are dropped.
+const eventBufferSize = 128
+
+// EventType describes what happened to a key.
+type EventType int
+
+const (
+	// EventSet is published when a key is written.
+	EventSet EventType = iota
+	// EventDeleted is published when a key is deleted, or removed by Clear or Stop.
+	EventDeleted
+	// EventExpired is published when an expired key is removed.
+	EventExpired
+	// EventEvicted is published when a key is evicted to make room in a bounded cache.
+	EventEvicted
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDeleted:
+		return "deleted"
+	case EventExpired:
+		return "expired"
+	case EventEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a key, see Subscribe. Value is the key's new value for EventSet, and the value that was removed
+// otherwise.
+type Event struct {
+	Key   string
+	Type  EventType
+	Value interface{}
+}
+
+// eventType maps the reason a key was removed to the event published for it. Overwritten keys don't publish a removal
+// as the EventSet for the new value covers them.
+func eventType(reason EvictReason) (EventType, bool) {
+	switch reason {
+	case ReasonExpired:
+		return EventExpired, true
+	case ReasonDeleted, ReasonFlushed:
+		return EventDeleted, true
+	case ReasonCapacity:
+		return EventEvicted, true
+	default:
+		return 0, false
+	}
+}
+
+// subscription is a single subscriber's channel of events.
+type subscription struct {
+	events chan Event
+	once   sync.Once
+
+	// keyed subscriptions made by WaitForKey only receive events for key. They only need the first one, so events
+	// that don't fit aren't counted as dropped.
+	keyed bool
+	key   string
+}
+
+// subscriptions holds every active subscription, count is kept separately so writes can cheaply skip queueing events
+// when nobody is listening.
+type subscriptions struct {
+	mutex  sync.RWMutex
+	subs   map[*subscription]struct{}
+	count  int32
+	closed bool
+}
+
+// Subscribe returns a channel of every change made to cache and a func that unsubscribes and closes the channel.
+// Events are published without blocking, so if the channel's buffer is full the event is dropped for that subscriber
+// and counted in Stats().DroppedEvents. Negative entries set by SetMissing don't publish events. Stop closes every
+// subscription, and Subscribe returns a closed channel until the cache is started again.
+func (h *Hotcache) Subscribe() (<-chan Event, func()) {
+	return h.subscribe(&subscription{events: make(chan Event, eventBufferSize)})
+}
+
+// subscribe adds a subscription, returning its channel and a func that unsubscribes, see Subscribe.
+func (h *Hotcache) subscribe(sub *subscription) (<-chan Event, func()) {
+	h.subscriptions.mutex.Lock()
+	if h.subscriptions.closed {
+		h.subscriptions.mutex.Unlock()
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	h.subscriptions.subs[sub] = struct{}{}
+	atomic.AddInt32(&h.subscriptions.count, 1)
+	h.subscriptions.mutex.Unlock()
+
+	return sub.events, func() {
+		h.subscriptions.mutex.Lock()
+		defer h.subscriptions.mutex.Unlock()
+		h.unsubscribe(sub)
+	}
+}
+
+// unsubscribe removes a subscription and closes its channel, assumes the subscriptions mutex is held.
+func (h *Hotcache) unsubscribe(sub *subscription) {
+	sub.once.Do(func() {
+		delete(h.subscriptions.subs, sub)
+		atomic.AddInt32(&h.subscriptions.count, -1)
+		close(sub.events)
+	})
+}
+
+// openSubscriptions lets Subscribe be used again after closeSubscriptions.
+func (h *Hotcache) openSubscriptions() {
+	h.subscriptions.mutex.Lock()
+	defer h.subscriptions.mutex.Unlock()
+
+	h.subscriptions.closed = false
+}
+
+// closeSubscriptions unsubscribes everyone, any later calls to Subscribe return a closed channel until
+// openSubscriptions is called.
+func (h *Hotcache) closeSubscriptions() {
+	h.subscriptions.mutex.Lock()
+	defer h.subscriptions.mutex.Unlock()
+
+	h.subscriptions.closed = true
+	for sub := range h.subscriptions.subs {
+		h.unsubscribe(sub)
+	}
+}
+
+// recordEvent queues an event to be published once the shard's store mutex is released, it's skipped when there are
+// no subscribers. Assumes the store mutex is held.
+func (h *Hotcache) recordEvent(s *shard, key string, typ EventType, value interface{}) {
+	if atomic.LoadInt32(&h.subscriptions.count) == 0 {
+		return
+	}
+	s.events = append(s.events, Event{Key: key, Type: typ, Value: value})
+}
+
+// takeEvents returns and resets the shard's queued events, assumes the store mutex is held.
+func (s *shard) takeEvents() []Event {
+	events := s.events
+	s.events = nil
+	return events
+}
+
+// publish sends each event to every subscriber that has room for it, it must be called without holding any store
+// mutex.
+func (h *Hotcache) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.subscriptions.mutex.RLock()
+	defer h.subscriptions.mutex.RUnlock()
+
+	for _, e := range events {
+		for sub := range h.subscriptions.subs {
+			if sub.keyed && sub.key != e.Key {
+				continue
+			}
+			select {
+			case sub.events <- e:
+			default:
+				if !sub.keyed {
+					atomic.AddUint64(&h.stats.droppedEvents, 1)
+				}
+			}
+		}
+	}
+}
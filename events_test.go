@@ -0,0 +1,213 @@
+package hotcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drainEvents reads every event currently buffered in a subscription, stopping early if it's closed.
+func drainEvents(events <-chan Event) []Event {
+	drained := make([]Event, 0)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return drained
+			}
+			drained = append(drained, e)
+		default:
+			return drained
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd", "xd2", 0)
+	cache.Delete("xd")
+	cache.Delete("xd")
+
+	assert.Equal(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventSet, Value: "xd"},
+		{Key: "xd", Type: EventSet, Value: "xd2"},
+		{Key: "xd", Type: EventDeleted, Value: "xd2"},
+	})
+}
+
+func TestSubscribeDeleteIfExists(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	assert.Equal(t, cache.DeleteIfExists("xd"), true)
+	assert.Equal(t, cache.DeleteIfExists("xd"), false)
+
+	assert.Equal(t, drainEvents(events), []Event{{Key: "xd", Type: EventDeleted, Value: "xd"}})
+}
+
+func TestSubscribeExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	clock.Advance(time.Millisecond * 10)
+	cache.Get("xd")
+
+	assert.Equal(t, drainEvents(events), []Event{{Key: "xd", Type: EventExpired, Value: "xd"}})
+}
+
+func TestSubscribeEvicted(t *testing.T) {
+	cache := New(WithMaxKeys(1))
+	defer cache.Stop()
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", 0)
+
+	assert.Equal(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventSet, Value: "xd"},
+		{Key: "xd2", Type: EventSet, Value: "xd2"},
+		{Key: "xd", Type: EventEvicted, Value: "xd"},
+	})
+}
+
+func TestSubscribeSkipsNegative(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.SetMissing("xd", 0)
+	cache.Delete("xd")
+
+	assert.Equal(t, len(drainEvents(events)), 0)
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < eventBufferSize+10; i++ {
+		cache.Set("xd", i, 0)
+	}
+
+	assert.Equal(t, len(drainEvents(events)), eventBufferSize)
+	assert.Equal(t, cache.Stats().DroppedEvents, uint64(10))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	events, unsubscribe := cache.Subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	cache.Set("xd", "xd", 0)
+
+	_, open := <-events
+	assert.Equal(t, open, false)
+	assert.Equal(t, cache.Stats().DroppedEvents, uint64(0))
+}
+
+func TestSubscribeStop(t *testing.T) {
+	cache := New()
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.Set("xd", "xd", 0)
+	cache.Stop()
+
+	assert.Equal(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventSet, Value: "xd"},
+		{Key: "xd", Type: EventDeleted, Value: "xd"},
+	})
+
+	_, open := <-events
+	assert.Equal(t, open, false)
+
+	// Subscribing after Stop returns a closed channel.
+	events, _ = cache.Subscribe()
+	_, open = <-events
+	assert.Equal(t, open, false)
+
+	// Until the cache is started again.
+	cache.Start()
+	defer cache.Stop()
+
+	events, unsubscribe = cache.Subscribe()
+	cache.Set("xd", "xd", 0)
+	unsubscribe()
+	assert.Equal(t, drainEvents(events), []Event{{Key: "xd", Type: EventSet, Value: "xd"}})
+}
+
+func TestSubscribeRename(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	events, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.Rename("xd", "xd2")
+
+	assert.ElementsMatch(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventDeleted, Value: "xd"},
+		{Key: "xd2", Type: EventSet, Value: "xd"},
+	})
+}
+
+func TestSubscribeConcurrent(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				events, unsubscribe := cache.Subscribe()
+				cache.Set(strconv.Itoa(g), i, 0)
+				drainEvents(events)
+				unsubscribe()
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestEventTypeString(t *testing.T) {
+	assert.Equal(t, EventSet.String(), "set")
+	assert.Equal(t, EventDeleted.String(), "deleted")
+	assert.Equal(t, EventExpired.String(), "expired")
+	assert.Equal(t, EventEvicted.String(), "evicted")
+	assert.Equal(t, EventType(-1).String(), "unknown")
+}
@@ -0,0 +1,168 @@
+package hotcache
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// EvictReason describes why a key was removed from cache.
+type EvictReason int
+
+const (
+	// ReasonExpired is used when a key is removed because its TTL passed.
+	ReasonExpired EvictReason = iota
+	// ReasonDeleted is used when a key is explicitly deleted.
+	ReasonDeleted
+	// ReasonReplaced is used when a key is overwritten by a new value.
+	ReasonReplaced
+	// ReasonFlushed is used when a key is removed by Clear or Stop.
+	ReasonFlushed
+	// ReasonCapacity is used when a key is evicted to make room in a bounded cache.
+	ReasonCapacity
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonFlushed:
+		return "flushed"
+	case ReasonCapacity:
+		return "capacity"
+	default:
+		return "unknown"
+	}
+}
+
+// OnEvictFunc is called with every key that leaves the cache, see WithOnEvict.
+type OnEvictFunc func(key string, value interface{}, reason EvictReason)
+
+// eviction is a removed key waiting for the OnEvict callback to be called with it.
+type eviction struct {
+	key    string
+	value  interface{}
+	reason EvictReason
+}
+
+// recordEviction counts a removed key and queues it for the OnEvict callback, which is called once the shard's store
+// mutex is released. Overwrites are queued for logging too when debug logs are enabled. Assumes the store mutex is
+// held.
+func (h *Hotcache) recordEviction(s *shard, key string, value interface{}, reason EvictReason) {
+	if reason != ReasonReplaced {
+		atomic.AddUint64(&h.stats.evictions, 1)
+	}
+	h.options.metrics.IncEviction(reason)
+
+	if h.options.onEvict == nil && (reason != ReasonReplaced || !h.debugEnabled()) {
+		return
+	}
+	s.evictions = append(s.evictions, eviction{key: key, value: value, reason: reason})
+}
+
+// unlockStore releases a shard's store mutex and then calls the OnEvict callback with any keys removed while it was
+// held, and publishes any changes made to subscribers. Callbacks are called outside of the lock so they're free to use
+// the cache. Keys added while the lock was held may have pushed the cache over its bound, so that's enforced here too.
+func (h *Hotcache) unlockStore(s *shard) {
+	if h.options.readOptimized {
+		s.publishSnapshot()
+	}
+	evictions, events := s.takeEvictions(), s.takeEvents()
+	s.storeMutex.Unlock()
+
+	h.afterUnlock(evictions, events)
+}
+
+// afterUnlock calls the OnEvict callback and publishes events queued while a store mutex was held, then enforces the
+// cache's bounds. It must be called without holding any store mutex.
+func (h *Hotcache) afterUnlock(evictions []eviction, events []Event) {
+	h.notifyEvictions(evictions)
+	h.publish(events)
+
+	if h.tracker != nil {
+		h.enforceBounds()
+	}
+}
+
+// lockStores obtains the store mutex of two shards, which may be the same shard. They're always obtained in the order
+// the shards were created in, so two callers locking the same pair can't deadlock.
+func (h *Hotcache) lockStores(a, b *shard) {
+	if a == b {
+		a.storeMutex.Lock()
+		return
+	}
+
+	if a.index > b.index {
+		a, b = b, a
+	}
+	a.storeMutex.Lock()
+	b.storeMutex.Lock()
+}
+
+// unlockStores releases the store mutexes obtained by lockStores, then handles anything removed or changed while they
+// were held in the same way as unlockStore.
+func (h *Hotcache) unlockStores(a, b *shard) {
+	if a == b {
+		h.unlockStore(a)
+		return
+	}
+
+	if h.options.readOptimized {
+		a.publishSnapshot()
+		b.publishSnapshot()
+	}
+	evictions := append(a.takeEvictions(), b.takeEvictions()...)
+	events := append(a.takeEvents(), b.takeEvents()...)
+	a.storeMutex.Unlock()
+	b.storeMutex.Unlock()
+
+	h.afterUnlock(evictions, events)
+}
+
+// lockShards obtains the store mutex of several distinct shards, which must be sorted in the order the shards were
+// created in so callers locking overlapping shards can't deadlock, like lockStores.
+func (h *Hotcache) lockShards(shards []*shard) {
+	for _, s := range shards {
+		s.storeMutex.Lock()
+	}
+}
+
+// unlockShards releases the store mutexes obtained by lockShards, returning the evictions and events queued while they
+// were held, which must be passed to afterUnlock once no store mutex of any cache is held.
+func (h *Hotcache) unlockShards(shards []*shard) ([]eviction, []Event) {
+	var evictions []eviction
+	var events []Event
+	for _, s := range shards {
+		if h.options.readOptimized {
+			s.publishSnapshot()
+		}
+		evictions = append(evictions, s.takeEvictions()...)
+		events = append(events, s.takeEvents()...)
+		s.storeMutex.Unlock()
+	}
+	return evictions, events
+}
+
+// takeEvictions returns and resets the shard's queued evictions, assumes the store mutex is held.
+func (s *shard) takeEvictions() []eviction {
+	evictions := s.evictions
+	s.evictions = nil
+	return evictions
+}
+
+// notifyEvictions calls the OnEvict callback with each eviction and logs overwrites, it must be called without holding
+// any store mutex.
+func (h *Hotcache) notifyEvictions(evictions []eviction) {
+	for _, e := range evictions {
+		if e.reason == ReasonReplaced && h.debugEnabled() {
+			h.debug("hotcache: key overwritten", slog.String("key", e.key))
+		}
+		if h.options.onEvict != nil {
+			h.options.onEvict(e.key, e.value, e.reason)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package hotcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// evictionRecorder records every call to an OnEvict callback.
+type evictionRecorder struct {
+	mutex     sync.Mutex
+	evictions []eviction
+}
+
+func (r *evictionRecorder) onEvict(key string, value interface{}, reason EvictReason) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.evictions = append(r.evictions, eviction{key: key, value: value, reason: reason})
+}
+
+func (r *evictionRecorder) get() []eviction {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]eviction(nil), r.evictions...)
+}
+
+func TestOnEvictDeleted(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Delete("xd")
+
+	// Deleting a missing key doesn't evict anything.
+	cache.Delete("xd")
+
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonDeleted}})
+}
+
+func TestOnEvictReplaced(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd", "xd2", 0)
+
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonReplaced}})
+}
+
+func TestOnEvictExpired(t *testing.T) {
+	recorder := &evictionRecorder{}
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	cache.Set("xd2", "xd2", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	// Evicted on lookup.
+	cache.Get("xd")
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonExpired}})
+
+	// Evicted by the ticker.
+	cache.tick()
+	assert.Equal(t, recorder.get(), []eviction{
+		{key: "xd", value: "xd", reason: ReasonExpired},
+		{key: "xd2", value: "xd2", reason: ReasonExpired},
+	})
+}
+
+func TestOnEvictOverwriteExpired(t *testing.T) {
+	recorder := &evictionRecorder{}
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+	cache.Set("xd", "xd2", 0)
+
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonExpired}})
+}
+
+func TestOnEvictFlushed(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", 0)
+	cache.Clear()
+
+	assert.ElementsMatch(t, recorder.get(), []eviction{
+		{key: "xd", value: "xd", reason: ReasonFlushed},
+		{key: "xd2", value: "xd2", reason: ReasonFlushed},
+	})
+}
+
+func TestOnEvictCapacity(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithMaxKeys(1), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", 0)
+
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonCapacity}})
+}
+
+func TestOnEvictReentrant(t *testing.T) {
+	var cache *Hotcache
+	cache = New(WithOnEvict(func(key string, value interface{}, reason EvictReason) {
+		// Using the cache from the callback must not deadlock.
+		cache.Set("evicted:"+key, value, 0)
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Delete("xd")
+
+	val, ok := cache.Get("evicted:xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+}
@@ -1,8 +1,11 @@
 module github.com/aidenwallis/hotcache
 
-go 1.12
+go 1.21
+
+require github.com/stretchr/testify v1.4.0
 
 require (
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/lint v0.0.0-20191125180803-fdd1cda4f05f // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -0,0 +1,130 @@
+package hotcache
+
+import "time"
+
+// Hashes are stored as a map[string]interface{} of their fields. Like every other value, the map is never modified
+// once it's stored, so each change stores a new copy, and maps returned by Get must not be modified either.
+
+// HSet sets a field of the hash stored at key, creating the hash if the key is missing, and returns 1 if the field is
+// new or 0 if it replaced an existing field. Like Set, the key's expiry is reset to expiration, use 0 for no expiry.
+// If key holds a value that isn't a hash nothing is set and 0 is returned.
+func (h *Hotcache) HSet(key, field string, value interface{}, expiration time.Duration) int {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	exists = exists && old.live(h.now())
+
+	var current map[string]interface{}
+	if exists {
+		hash, ok := old.value.(map[string]interface{})
+		if !ok {
+			return 0
+		}
+		current = hash
+	}
+
+	added := 1
+	if _, ok := current[field]; ok {
+		added = 0
+	}
+
+	updated := make(map[string]interface{}, len(current)+1)
+	for f, v := range current {
+		updated[f] = v
+	}
+	updated[field] = value
+
+	if !exists {
+		h.set(s, key, updated, expiration)
+		return added
+	}
+
+	h.replaceValue(s, key, old, updated)
+	replaced := s.store[key]
+	h.update(s, key, replaced, h.withExpiry(replaced, expiration))
+	return added
+}
+
+// HGet retrieves a field of the hash stored at key. It returns false if the key is missing, expired, or isn't a hash,
+// or the field isn't set.
+func (h *Hotcache) HGet(key, field string) (interface{}, bool) {
+	hash, ok := h.getHash(key)
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := hash[field]
+	return value, ok
+}
+
+// HGetAll returns a copy of every field of the hash stored at key, or nil if the key is missing, expired, or isn't a
+// hash.
+func (h *Hotcache) HGetAll(key string) map[string]interface{} {
+	hash, ok := h.getHash(key)
+	if !ok {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, len(hash))
+	for f, v := range hash {
+		fields[f] = v
+	}
+	return fields
+}
+
+// HDel removes fields from the hash stored at key and returns how many were removed. The key is deleted once its hash
+// has no fields left.
+func (h *Hotcache) HDel(key string, fields ...string) int {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	if !exists || !old.live(h.now()) {
+		return 0
+	}
+
+	current, ok := old.value.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+
+	updated := make(map[string]interface{}, len(current))
+	for f, v := range current {
+		updated[f] = v
+	}
+
+	removed := 0
+	for _, field := range fields {
+		if _, ok := updated[field]; ok {
+			delete(updated, field)
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		return 0
+	}
+
+	if len(updated) == 0 {
+		h.remove(s, key, old, ReasonDeleted)
+	} else {
+		h.replaceValue(s, key, old, updated)
+	}
+	return removed
+}
+
+// getHash retrieves the hash stored at key, it must not be modified.
+func (h *Hotcache) getHash(key string) (map[string]interface{}, bool) {
+	val, ok := h.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	hash, ok := val.(map[string]interface{})
+	return hash, ok
+}
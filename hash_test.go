@@ -0,0 +1,125 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHSet(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.HSet("user", "name", "xd", 0), 1)
+	assert.Equal(t, cache.HSet("user", "age", 20, 0), 1)
+
+	val, ok := cache.HGet("user", "name")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	assert.Equal(t, cache.HGetAll("user"), map[string]interface{}{"name": "xd", "age": 20})
+
+	assert.Equal(t, cache.HSet("user", "name", "xd2", 0), 0)
+	val, _ = cache.HGet("user", "name")
+	assert.Equal(t, val, "xd2")
+}
+
+func TestHSetWrongType(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("user", "xd", 0)
+
+	_, ok := cache.HGet("user", "name")
+	assert.Equal(t, ok, false)
+	assert.Nil(t, cache.HGetAll("user"))
+	assert.Equal(t, cache.HDel("user", "name"), 0)
+
+	// The string is left in place rather than replaced with a hash.
+	assert.Equal(t, cache.HSet("user", "name", "xd", time.Second), 0)
+	val, _ := cache.Get("user")
+	assert.Equal(t, val, "xd")
+	ttl, _ := cache.TTL("user")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Nil(t, cache.HGetAll("user"))
+}
+
+func TestHGetMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	val, ok := cache.HGet("user", "name")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Nil(t, cache.HGetAll("user"))
+
+	cache.HSet("user", "name", "xd", 0)
+
+	_, ok = cache.HGet("user", "age")
+	assert.Equal(t, ok, false)
+}
+
+func TestHGetAllCopies(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.HSet("user", "name", "xd", 0)
+
+	fields := cache.HGetAll("user")
+	fields["name"] = "xd2"
+
+	val, _ := cache.HGet("user", "name")
+	assert.Equal(t, val, "xd")
+}
+
+func TestHDel(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.HDel("user", "name"), 0)
+
+	cache.HSet("user", "name", "xd", 0)
+	cache.HSet("user", "age", 20, 0)
+	cache.HSet("user", "country", "nz", 0)
+
+	assert.Equal(t, cache.HDel("user", "name", "missing"), 1)
+	assert.Equal(t, cache.HGetAll("user"), map[string]interface{}{"age": 20, "country": "nz"})
+
+	// Removing the last fields deletes the key.
+	assert.Equal(t, cache.HDel("user", "age", "country"), 2)
+	assert.Equal(t, cache.Has("user"), false)
+}
+
+func TestHashExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.HSet("user", "name", "xd", time.Second)
+	clock.Advance(time.Millisecond * 600)
+
+	// Setting a field resets the key's expiry.
+	cache.HSet("user", "age", 20, time.Second)
+	ttl, _ := cache.TTL("user")
+	assert.Equal(t, ttl, time.Second)
+
+	// Deleting a field keeps it.
+	cache.HSet("user", "country", "nz", time.Second)
+	clock.Advance(time.Millisecond * 600)
+	cache.HDel("user", "country")
+	ttl, _ = cache.TTL("user")
+	assert.Equal(t, ttl, time.Millisecond*400)
+
+	clock.Advance(time.Millisecond * 400)
+	_, ok := cache.HGet("user", "name")
+	assert.Equal(t, ok, false)
+	assert.Nil(t, cache.HGetAll("user"))
+
+	// The expiry is removed by setting a field without one.
+	cache.HSet("user", "name", "xd", time.Second)
+	cache.HSet("user", "age", 20, 0)
+	ttl, _ = cache.TTL("user")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache)
+}
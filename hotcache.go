@@ -1,227 +1,1801 @@
 package hotcache
 
 import (
+	"log/slog"
+	"math"
 	"math/rand"
+	"path"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// NoExpiry is returned by TTL for keys that never expire. It can also be passed as an expiration to store a key without
+// an expiry, which is the only way to do so with WithZeroTTLMeansImmediate.
+const NoExpiry time.Duration = -1
+
+// lastCacheID is the id given to the most recently created cache.
+var lastCacheID uint64
+
 // cacheValue is what we nest the stored values in Hotcache with, essentially to hold metadata.
 type cacheValue struct {
 	expiry time.Time
 	value  interface{}
+
+	// ttl is the duration the expiry was last calculated from, so Touch can re-apply it.
+	ttl time.Duration
+
+	// hard is when a value set by SetWithSoftTTL is evicted, having gone stale once expiry passed. It's the zero time
+	// for values set any other way, which are kept for the WithStaleWindow instead.
+	hard time.Time
+
+	// negative marks a tombstone set by SetMissing, which only GetWithState reports.
+	negative bool
+
+	// cost is the caller provided cost of the value set by SetWithCost, counted towards WithMaxCost.
+	cost int64
+
+	// version is the caller provided version of the value set by SetIfGreater, 0 for values set any other way.
+	version int64
+
+	// access counts uses of the value with WithAccessTracking, nil otherwise.
+	access *keyAccess
+}
+
+// expired checks whether the value has an expiry and it has been reached.
+func (v *cacheValue) expired(now time.Time) bool {
+	return !v.expiry.IsZero() && !v.expiry.After(now)
+}
+
+// newValue wraps a value to be stored, calculating its expiry from expiration, see expiryFor.
+func (h *Hotcache) newValue(value interface{}, expiration time.Duration) *cacheValue {
+	return &cacheValue{
+		expiry: h.expiryFor(expiration),
+		value:  value,
+		ttl:    expiration,
+	}
+}
+
+// copy returns a copy of the value, values are replaced rather than modified so readers that have already released
+// the lock never see a partial update.
+func (v *cacheValue) copy() *cacheValue {
+	c := *v
+	return &c
+}
+
+// live checks whether the value should be visible to callers, meaning it hasn't expired and isn't a tombstone.
+func (v *cacheValue) live(now time.Time) bool {
+	return !v.negative && !v.expired(now)
 }
 
 type Hotcache struct {
-	// Adds thread-safety
-	expiryMutex sync.RWMutex
-	storeMutex  sync.RWMutex
+	options options
+
+	// Unique to each cache, so operations that lock two caches can always lock them in the same order.
+	id uint64
+
+	// The store is split into shards to reduce lock contention, keys are routed to a shard by their hash.
+	shards []*shard
+
+	// Number of keys held across every shard, including expired keys that haven't been evicted yet.
+	count int64
+
+	// Total cost of the values held across every shard, see SetWithCost.
+	cost int64
+
+	// Number of keys the cache is bounded to by WithMaxKeys or SetMaxKeys, 0 if it isn't.
+	maxKeys int64
+
+	// Tracks key usage for the eviction policy when the cache is bounded by WithMaxKeys or WithMaxCost, nil otherwise.
+	tracker evictionTracker
 
-	// Small list of all keys that have an expiry on them, it doesn't have to be perfectly in sync as the expiry ticker will remove any redundant ones.
-	expiringKeys []string
+	// Filter of every key in cache when WithNegativeBloom is set, nil otherwise.
+	bloom *negativeBloom
+
+	// Values waiting to be written when WithWriter is set with WriteBehind, nil otherwise.
+	writes *writeQueue
+
+	// Counters behind Stats, kept behind a pointer so they're 64-bit aligned for atomic operations.
+	stats *stats
+
+	// Whether the garbage collecting ticker and other background goroutines are running, and whether the cache has
+	// been stopped since it was last started, see Start and Stop. A cache created with WithStartPaused is neither. done
+	// is closed by Stop to shut the goroutines down, and background tracks them so Stop can wait for them to exit.
+	stateMutex sync.Mutex
+	running    bool
+	stopped    bool
+	ticker     *time.Ticker
+	done       chan struct{}
+	background sync.WaitGroup
+
+	// Subscribers to changes made to cache, see Subscribe.
+	subscriptions subscriptions
+
+	// In-flight GetOrCompute calls, keyed by the key being computed.
+	callMutex sync.Mutex
+	calls     map[string]*call
+
+	// Keys currently being reloaded by WithRefreshAhead.
+	refreshMutex sync.Mutex
+	refreshing   map[string]struct{}
+
+	// Random source used to pick which expiring keys to check, seeded once when the cache is created unless one is set
+	// by WithRandSource.
+	randMutex sync.Mutex
+	rand      *rand.Rand
+}
 
-	// The actual cache store
-	store map[string]*cacheValue
+// New creates a new cache and starts its garbage collecting ticker, Stop must be called once you're done with it.
+func New(opts ...Option) *Hotcache {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	// Ticker is what runs the garbage collection on a set interval.
-	ticker *time.Ticker
+	return newWithOptions(o)
 }
 
-func New() *Hotcache {
+// newWithOptions creates a new cache from options that have already been applied.
+func newWithOptions(o options) *Hotcache {
 	h := &Hotcache{
-		expiringKeys: make([]string, 0),
-		store:        make(map[string]*cacheValue),
-		ticker:       time.NewTicker(time.Millisecond * 100),
+		id:         atomic.AddUint64(&lastCacheID, 1),
+		options:    o,
+		shards:     make([]*shard, o.shards),
+		calls:      make(map[string]*call),
+		refreshing: make(map[string]struct{}),
+		stats:      &stats{},
+		maxKeys:    int64(o.maxKeys),
+		subscriptions: subscriptions{
+			subs: make(map[*subscription]struct{}),
+		},
+	}
+
+	if o.randSource != nil {
+		h.rand = rand.New(o.randSource)
+	} else {
+		h.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	// Split the hint between every shard, as keys are evenly distributed between them.
+	capacity := (o.capacityHint + len(h.shards) - 1) / len(h.shards)
+	for i := range h.shards {
+		h.shards[i] = newShard(i, capacity)
+		if o.wheelResolution > 0 {
+			h.shards[i].wheel = newTimingWheel(o.wheelResolution, h.now())
+		}
+	}
+
+	if o.maxKeys > 0 || o.maxCost > 0 {
+		h.tracker = newEvictionTracker(o.evictionPolicy)
 	}
 
-	go h.startTicker()
+	if o.bloomSize > 0 {
+		h.bloom = newNegativeBloom(o.bloomSize, o.bloomHashes)
+	}
+
+	if o.writer != nil && o.writeMode == WriteBehind {
+		h.writes = newWriteQueue()
+	}
+
+	if !o.startPaused {
+		h.Start()
+	}
 
 	return h
 }
 
-// Stop must be called when you are done with the tempcache, as it will stop the garbage collecting ticker.
+// Clone creates a new cache with the same options and a copy of every key that hasn't expired, along with when it
+// expires. The clone has its own store and ticker, so changes to one cache don't affect the other, and Stop must be
+// called on it too. Values themselves aren't copied, so pointers, maps, and slices are shared between both caches.
+func (h *Hotcache) Clone() *Hotcache {
+	o := h.options
+	o.maxKeys = int(atomic.LoadInt64(&h.maxKeys))
+	if o.randSource != nil {
+		// Sources aren't safe for concurrent use, so the clone can't share this cache's.
+		o.randSource = rand.NewSource(h.randInt63n(math.MaxInt64))
+	}
+	clone := newWithOptions(o)
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		// Both caches have the same number of shards, so keys are routed to the same shard in the clone.
+		target := clone.shards[s.index]
+		target.storeMutex.Lock()
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if val.expired(now) {
+				continue
+			}
+			// The clone counts uses separately, so it doesn't share access counts with this cache.
+			copied := val.copy()
+			copied.access = nil
+			clone.setValue(target, key, copied)
+		}
+		s.storeMutex.RUnlock()
+		clone.unlockStore(target)
+	}
+
+	return clone
+}
+
+// Start starts the garbage collecting ticker of a cache created with WithStartPaused, or restarts it after Stop and
+// lets Subscribe be used again. New starts the cache itself otherwise. Starting a running cache does nothing.
+func (h *Hotcache) Start() {
+	h.stateMutex.Lock()
+	defer h.stateMutex.Unlock()
+
+	if h.running {
+		return
+	}
+	h.running = true
+	h.stopped = false
+
+	h.ticker = time.NewTicker(h.options.tickInterval)
+	h.done = make(chan struct{})
+	h.openSubscriptions()
+
+	h.background.Add(1)
+	go h.startTicker(h.ticker, h.done)
+
+	if h.options.statsInterval > 0 {
+		h.background.Add(1)
+		go h.reportStats(h.done)
+	}
+
+	if h.writes != nil {
+		h.background.Add(1)
+		go h.writeBehind(h.done)
+	}
+
+	if h.options.compactionInterval > 0 {
+		h.background.Add(1)
+		go h.compactor(h.done)
+	}
+}
+
+// Stop must be called when you are done with the cache. It stops the garbage collecting ticker, removes every key, and
+// closes every subscription. A stopped cache can still be used, but nothing removes expired keys until they're looked
+// up, so call Start before using it again. Stopping a stopped cache does nothing, but a cache that was never started
+// because of WithStartPaused is still cleared.
 func (h *Hotcache) Stop() {
-	h.ticker.Stop()
+	h.stateMutex.Lock()
+	defer h.stateMutex.Unlock()
+
+	if h.stopped {
+		return
+	}
+	h.stopped = true
+
+	if h.running {
+		h.running = false
+		h.ticker.Stop()
+		close(h.done)
+		h.background.Wait()
+	}
+
+	if h.writes != nil {
+		// Nothing's left to wait for, so failed writes are retried straight away.
+		done := make(chan struct{})
+		close(done)
+		h.flushWrites(done)
+	}
+
+	h.Clear()
+	h.closeSubscriptions()
+}
+
+// Clear removes every key from cache, unlike Stop the cache remains usable afterwards. Shards are cleared one at a
+// time, so keys set concurrently with Clear may survive it.
+func (h *Hotcache) Clear() {
+	for _, s := range h.shards {
+		s.storeMutex.Lock()
 
-	// Clear expiry list
-	h.expiryMutex.Lock()
-	h.expiringKeys = make([]string, 0)
-	h.expiryMutex.Unlock()
+		// Expiring keys are reset first so removing each key doesn't have to find it in the list.
+		s.resetExpiry()
+		for key, val := range s.store {
+			h.remove(s, key, val, ReasonFlushed)
+		}
+		s.store = make(map[string]*cacheValue, s.capacity)
+
+		h.unlockStore(s)
+	}
 
-	// Clear hashmap
-	h.storeMutex.Lock()
-	h.store = make(map[string]*cacheValue)
-	h.storeMutex.Unlock()
+	if h.bloom != nil {
+		h.rebuildBloom()
+	}
 }
 
 // Get retrieves a key that isn't expired from cache
 func (h *Hotcache) Get(key string) (interface{}, bool) {
-	h.storeMutex.RLock()
-	val, ok, expired := h.get(key)
-	h.storeMutex.RUnlock()
+	val, ok, _ := h.lookup(key)
+	return val, ok
+}
+
+// GetWithExpiry retrieves a key that isn't expired from cache like Get, along with when it expires. The zero time is
+// returned for keys without an expiry.
+func (h *Hotcache) GetWithExpiry(key string) (value interface{}, expiresAt time.Time, ok bool) {
+	val, ok, _ := h.lookupValue(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return val.value, val.expiry, true
+}
+
+// GetDetailed retrieves a key that isn't expired from cache like Get, also reporting whether a miss was because the key
+// had expired rather than never being set. Once an expired key is evicted it's reported as a plain miss.
+func (h *Hotcache) GetDetailed(key string) (value interface{}, ok bool, expired bool) {
+	return h.lookup(key)
+}
+
+// lookup retrieves a key for Get, Has, and GetDetailed, evicting it if it's expired.
+func (h *Hotcache) lookup(key string) (interface{}, bool, bool) {
+	val, ok, expired := h.lookupValue(key)
+	if !ok {
+		return nil, false, expired
+	}
+	return val.value, true, false
+}
+
+// lookupValue is lookup returning the stored value, so callers can read more than just the value.
+func (h *Hotcache) lookupValue(key string) (*cacheValue, bool, bool) {
+	if h.bloom != nil && !h.bloom.mayContain(key) {
+		h.recordLookup(false)
+		return nil, false, false
+	}
+
+	s := h.shard(key)
+	now := h.now()
+
+	var val *cacheValue
+	var ok bool
+	if h.options.readOptimized {
+		val, ok = s.loadSnapshot()[key]
+	} else {
+		s.storeMutex.RLock()
+		val, ok = s.store[key]
+		s.storeMutex.RUnlock()
+	}
+
+	expired := ok && val.expired(now)
+	ok = ok && !expired && !val.negative
+	if ok {
+		h.access(key, val)
+	}
+
+	h.recordLookup(ok)
 
 	if expired {
-		h.storeMutex.Lock()
-		h.evict(key)
-		h.storeMutex.Unlock()
+		s.storeMutex.Lock()
+		h.evict(s, key)
+		h.unlockStore(s)
 	}
 
-	return val, ok
+	if !ok {
+		return nil, false, expired
+	}
+	return val, true, false
+}
+
+// SetDefault adds a key to store using the expiration configured with WithDefaultTTL, see Set.
+func (h *Hotcache) SetDefault(key string, value interface{}) {
+	h.Set(key, value, h.options.defaultTTL)
+}
+
+// GetMulti retrieves every key that isn't expired from cache, keys that are missing or expired are left out of the
+// result. Keys are grouped by shard so each shard's lock is only obtained once, rather than once per key.
+func (h *Hotcache) GetMulti(keys []string) map[string]interface{} {
+	results := make(map[string]interface{}, len(keys))
+
+	for i, group := range h.groupByShard(keys) {
+		if len(group) == 0 {
+			continue
+		}
+
+		s := h.shards[i]
+		now := h.now()
+		var expired []string
+
+		s.storeMutex.RLock()
+		for _, key := range group {
+			val, ok := s.store[key]
+			isExpired := ok && val.expired(now)
+			ok = ok && !isExpired && !val.negative
+			if ok {
+				h.access(key, val)
+				results[key] = val.value
+			} else if isExpired {
+				expired = append(expired, key)
+			}
+			h.recordLookup(ok)
+		}
+		s.storeMutex.RUnlock()
+
+		// Expired keys are evicted once the read lock is released, as it can't be upgraded mid-iteration.
+		if len(expired) > 0 {
+			s.storeMutex.Lock()
+			for _, key := range expired {
+				h.evict(s, key)
+			}
+			h.unlockStore(s)
+		}
+	}
+
+	return results
 }
 
-// Set adds a key to store. Use expiration of 0 for no expiry. Note this will override the key if it's existing.
+// Set adds a key to store. Use expiration of 0 or NoExpiry for no expiry, or only NoExpiry with
+// WithZeroTTLMeansImmediate. Note this will override the key if it's existing. Values larger than the bound set by
+// WithMaxValueBytes, or that fail to be written with WithWriter, are ignored, use SetChecked to find out when that
+// happens.
 func (h *Hotcache) Set(key string, value interface{}, expiration time.Duration) {
-	h.storeMutex.Lock()
-	if expiration != 0 {
-		h.expiryMutex.Lock()
+	if h.tooLarge(value) || h.writeThrough(key, value) != nil {
+		return
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	h.set(s, key, value, expiration)
+	h.queueWrite(key, value)
+	h.unlockStore(s)
+}
+
+// SetWithCost adds a key to store along with its cost, such as its approximate size in bytes, see Set. Once the total
+// cost of every key exceeds the bound set by WithMaxCost, the least recently used keys are evicted until it's back
+// within the bound. Keys set without a cost have a cost of 0. Like Set, values larger than WithMaxValueBytes allows, or
+// that fail to be written with WithWriter, are ignored.
+func (h *Hotcache) SetWithCost(key string, value interface{}, cost int64, expiration time.Duration) {
+	if h.tooLarge(value) || h.writeThrough(key, value) != nil {
+		return
+	}
+
+	val := h.newValue(value, expiration)
+	val.cost = cost
+
+	s := h.shard(key)
+	s.storeMutex.Lock()
+	h.setValue(s, key, val)
+	h.queueWrite(key, value)
+	h.unlockStore(s)
+}
+
+// Cost returns the total cost of every key in cache, see SetWithCost.
+func (h *Hotcache) Cost() int64 {
+	return atomic.LoadInt64(&h.cost)
+}
+
+// SetMaxKeys changes the number of keys the cache is bounded to, as set by WithMaxKeys, and returns how many keys were
+// evicted to bring the cache within the new bound. Keys are evicted by the eviction policy, the same as when a Set
+// fills the cache, and concurrent writes are bounded by whichever limit they see. Use n of 0 to remove the bound. Only
+// caches created with WithMaxKeys or WithMaxCost track how keys are used, so on any other cache it does nothing.
+func (h *Hotcache) SetMaxKeys(n int) int {
+	if h.tracker == nil {
+		return 0
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	atomic.StoreInt64(&h.maxKeys, int64(n))
+	return h.enforceBounds()
+}
+
+// SetMulti adds every entry to store with the same expiration, see Set. Entries are grouped by shard so each shard's
+// locks are only obtained once, rather than once per key.
+func (h *Hotcache) SetMulti(entries map[string]interface{}, expiration time.Duration) {
+	groups := make([][]string, len(h.shards))
+	for key, value := range entries {
+		if h.tooLarge(value) || h.writeThrough(key, value) != nil {
+			continue
+		}
+
+		i := shardIndex(key, len(h.shards))
+		if groups[i] == nil {
+			groups[i] = make([]string, 0, len(entries)/len(h.shards)+1)
+		}
+		groups[i] = append(groups[i], key)
+	}
+
+	for i, keys := range groups {
+		if len(keys) == 0 {
+			continue
+		}
+
+		s := h.shards[i]
+		s.storeMutex.Lock()
+		for _, key := range keys {
+			h.put(s, key, h.newValue(entries[key], expiration))
+			h.queueWrite(key, entries[key])
+		}
+
+		if !h.expiryFor(expiration).IsZero() {
+			s.trackExpiry(keys...)
+		}
+		h.unlockStore(s)
+	}
+}
+
+// ValueTTL is a value along with the expiration to set it with, see SetMultiWithTTLs.
+type ValueTTL struct {
+	Value      interface{}
+	Expiration time.Duration
+}
+
+// SetMultiWithTTLs adds every entry to store with its own expiration, see Set. Like SetMulti, entries are grouped by
+// shard so each shard's locks are only obtained once.
+func (h *Hotcache) SetMultiWithTTLs(entries map[string]ValueTTL) {
+	groups := make([][]string, len(h.shards))
+	for key, entry := range entries {
+		if h.tooLarge(entry.Value) || h.writeThrough(key, entry.Value) != nil {
+			continue
+		}
+
+		i := shardIndex(key, len(h.shards))
+		groups[i] = append(groups[i], key)
+	}
+
+	for i, keys := range groups {
+		if len(keys) == 0 {
+			continue
+		}
+
+		s := h.shards[i]
+		s.storeMutex.Lock()
+		for _, key := range keys {
+			entry := entries[key]
+			h.setValue(s, key, h.newValue(entry.Value, entry.Expiration))
+			h.queueWrite(key, entry.Value)
+		}
+		h.unlockStore(s)
+	}
+}
+
+// OverwritePolicy decides which keys SetManyWithPolicy writes.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways writes every key, like Set.
+	OverwriteAlways OverwritePolicy = iota
+	// OverwriteNever only writes keys that don't exist or have expired, like SetNX.
+	OverwriteNever
+	// OverwriteOnlyIfExists only writes keys that already exist and haven't expired, like Replace.
+	OverwriteOnlyIfExists
+)
+
+// String returns the name of the policy.
+func (p OverwritePolicy) String() string {
+	switch p {
+	case OverwriteAlways:
+		return "always"
+	case OverwriteNever:
+		return "never"
+	case OverwriteOnlyIfExists:
+		return "only if exists"
+	default:
+		return "unknown"
 	}
+}
+
+// SetManyWithPolicy adds entries to store with the same expiration, writing each key only if policy allows it, and
+// returns whether each key was written. Like SetMulti, entries are grouped by shard so each shard's locks are only
+// obtained once, and values larger than WithMaxValueBytes allows are skipped. Only OverwriteAlways writes values to the
+// Writer set by WithWriter, as the other policies depend on what's in cache, see WithWriter.
+func (h *Hotcache) SetManyWithPolicy(entries map[string]interface{}, expiration time.Duration, policy OverwritePolicy) map[string]bool {
+	written := make(map[string]bool, len(entries))
+
+	groups := make([][]string, len(h.shards))
+	for key, value := range entries {
+		if h.tooLarge(value) || (policy == OverwriteAlways && h.writeThrough(key, value) != nil) {
+			written[key] = false
+			continue
+		}
+
+		i := shardIndex(key, len(h.shards))
+		groups[i] = append(groups[i], key)
+	}
+
+	for i, keys := range groups {
+		if len(keys) == 0 {
+			continue
+		}
 
-	h.set(key, value, expiration)
+		s := h.shards[i]
+		now := h.now()
 
-	if expiration != 0 {
-		h.expiryMutex.Unlock()
+		s.storeMutex.Lock()
+		for _, key := range keys {
+			_, exists, _ := s.get(key, now)
+			if (policy == OverwriteNever && exists) || (policy == OverwriteOnlyIfExists && !exists) {
+				written[key] = false
+				continue
+			}
+
+			h.set(s, key, entries[key], expiration)
+			if policy == OverwriteAlways {
+				h.queueWrite(key, entries[key])
+			}
+			written[key] = true
+		}
+		h.unlockStore(s)
 	}
-	h.storeMutex.Unlock()
+
+	return written
 }
 
 // Has checks if a key is in cache and not expired
 func (h *Hotcache) Has(key string) bool {
-	h.storeMutex.RLock()
-	_, ok, expired := h.get(key)
-	h.storeMutex.RUnlock()
+	_, ok, _ := h.lookup(key)
+	return ok
+}
 
-	if expired {
-		h.storeMutex.Lock()
-		h.evict(key)
-		h.storeMutex.Unlock()
+// Peek retrieves a key that isn't expired from cache without counting it as a use, so it doesn't affect which key is
+// evicted next from a bounded cache and isn't counted in Stats. Expired keys are reported as missing but left for the
+// garbage collector to remove.
+func (h *Hotcache) Peek(key string) (interface{}, bool) {
+	s := h.shard(key)
+
+	if h.options.readOptimized {
+		val, ok := s.loadSnapshot()[key]
+		if !ok || !val.live(h.now()) {
+			return nil, false
+		}
+		return val.value, true
 	}
 
-	return ok
+	s.storeMutex.RLock()
+	val, ok, _ := s.get(key, h.now())
+	s.storeMutex.RUnlock()
+
+	return val, ok
 }
 
-func (h *Hotcache) Delete(key string) {
-	h.storeMutex.Lock()
-	delete(h.store, key)
-	h.storeMutex.Unlock()
+// TTL returns how long is left until a key expires, NoExpiry is returned for keys that don't have an expiry. The bool
+// is false if the key is missing or has expired.
+func (h *Hotcache) TTL(key string) (time.Duration, bool) {
+	s := h.shard(key)
+
+	s.storeMutex.RLock()
+	val, ok := s.store[key]
+	s.storeMutex.RUnlock()
+
+	if !ok || val.negative {
+		return 0, false
+	}
+
+	if val.expiry.IsZero() {
+		return NoExpiry, true
+	}
+
+	remaining := val.expiry.Sub(h.now())
+	if remaining <= 0 {
+		s.storeMutex.Lock()
+		h.evict(s, key)
+		h.unlockStore(s)
+		return 0, false
+	}
+
+	return remaining, true
 }
 
-// get assumes that the mutex lock has already been obtained.
-func (h *Hotcache) get(key string) (interface{}, bool, bool) {
-	val, ok := h.store[key]
+// Expire resets the TTL of a key that isn't expired, returning false if it doesn't exist. Use ttl of 0 or NoExpiry to
+// remove the key's expiry, or only NoExpiry with WithZeroTTLMeansImmediate.
+func (h *Hotcache) Expire(key string, ttl time.Duration) bool {
+	s := h.shard(key)
 
-	if !ok {
-		return nil, ok, false
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	if !ok || !val.live(h.now()) {
+		return false
 	}
 
-	if !val.expiry.IsZero() && val.expiry.Before(time.Now()) {
-		return nil, false, true
+	h.update(s, key, val, h.withExpiry(val, ttl))
+	return true
+}
+
+// ExpireAt sets a key that isn't expired to expire at t, returning false if it doesn't exist. Unlike Expire the expiry
+// is used exactly as given, so it isn't jittered and keeps its precision. A t that has already passed expires the key
+// straight away, and the zero time removes the key's expiry.
+func (h *Hotcache) ExpireAt(key string, t time.Time) bool {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	now := h.now()
+	if !ok || !val.live(now) {
+		return false
 	}
 
-	return val.value, ok, false
+	updated := val.copy()
+	updated.expiry = t
+	updated.hard = time.Time{}
+	updated.ttl = NoExpiry
+	if !t.IsZero() {
+		updated.ttl = t.Sub(now)
+	}
+	h.update(s, key, val, updated)
+	h.evict(s, key)
+	return true
 }
 
-// set assumes that the mutex lock has already been obtained.
-func (h *Hotcache) set(key string, value interface{}, expiration time.Duration) {
-	var expireAt time.Time
-	if expiration != 0 {
-		expireAt = time.Now().Add(expiration)
+// Persist removes the expiry of a key that isn't expired, so it's kept until it's deleted, returning false if it
+// doesn't exist. Keys that already don't expire are left as they are.
+func (h *Hotcache) Persist(key string) bool {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	if !ok || !val.live(h.now()) {
+		return false
 	}
 
-	h.store[key] = &cacheValue{
-		expiry: expireAt,
-		value:  value,
+	if !val.expiry.IsZero() {
+		h.update(s, key, val, h.withExpiry(val, NoExpiry))
 	}
+	return true
+}
+
+// withExpiry returns a copy of a value that expires after ttl from now, see expiryFor.
+func (h *Hotcache) withExpiry(val *cacheValue, ttl time.Duration) *cacheValue {
+	updated := val.copy()
+	updated.expiry = h.expiryFor(ttl)
+	updated.hard = time.Time{}
+	updated.ttl = ttl
+	return updated
+}
+
+// update stores updated in place of old, a modified copy of it, keeping expiringKeys in sync if it gained or lost an
+// expiry. Unlike put it doesn't count as a new value being set. Assumes the store mutex is held.
+func (h *Hotcache) update(s *shard, key string, old, updated *cacheValue) {
+	s.store[key] = updated
+	h.scheduleExpiry(s, key, updated)
 
-	if expiration != 0 {
-		h.expiringKeys = append(h.expiringKeys, key)
+	if !updated.expiry.IsZero() && old.expiry.IsZero() {
+		s.trackExpiry(key)
+	} else if updated.expiry.IsZero() && !old.expiry.IsZero() {
+		s.untrackExpiry(key)
 	}
 }
 
-func (h *Hotcache) SetNX(key string, value interface{}, expiration time.Duration) bool {
-	h.storeMutex.Lock()
-	defer h.storeMutex.Unlock()
+// Touch restarts the countdown of a key's TTL from now, using the TTL it was set with. It returns false if the key is
+// missing, expired, or doesn't have a TTL.
+func (h *Hotcache) Touch(key string) bool {
+	s := h.shard(key)
 
-	_, exists, _ := h.get(key)
-	if exists {
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	if !ok || val.expiry.IsZero() || !val.live(h.now()) {
 		return false
 	}
 
-	h.set(key, value, expiration)
+	h.update(s, key, val, h.restarted(val))
 	return true
 }
 
-// evict removes a key from cache that has expired, assumes a mutex is held
-func (h *Hotcache) evict(key string) {
-	// Note that we don't remove the key from h.expiringKeys, the slice is eventually consistent,
-	// meaning that it's fine that the key exists in there, as randomness should eventually check the
-	// key and remove it, it may not be as efficient on memory, but is far more performant than
-	// performing a linear search per eviction.
-	delete(h.store, key)
+// restarted returns a copy of a value with an expiry whose TTL restarts from now, see Touch.
+func (h *Hotcache) restarted(val *cacheValue) *cacheValue {
+	updated := val.copy()
+	updated.expiry = h.expiresAt(val.ttl)
+	if !val.hard.IsZero() {
+		// Keys set by SetWithSoftTTL keep the same stale period after their new expiry.
+		updated.hard = val.hard.Add(updated.expiry.Sub(val.expiry))
+	}
+	return updated
+}
+
+// Delete removes a key from cache.
+func (h *Hotcache) Delete(key string) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	if val, ok := s.store[key]; ok {
+		h.remove(s, key, val, ReasonDeleted)
+	}
+	h.unlockStore(s)
 }
 
-// startTicker starts the ticking process for garbage collection on it's own goroutine
-func (h *Hotcache) startTicker() {
-	for range h.ticker.C {
-		h.tick()
+// DeleteIfExists is Delete that returns whether a key that exists and isn't expired was removed. Like Delete, it
+// publishes an EventDeleted and reports the key to WithOnEvict as ReasonDeleted. Expired keys are cleaned up as
+// expired and reported as missing.
+func (h *Hotcache) DeleteIfExists(key string) bool {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	if !ok {
+		return false
+	}
+
+	if val.expired(h.now()) {
+		h.evict(s, key)
+		return false
 	}
+
+	h.remove(s, key, val, ReasonDeleted)
+	return !val.negative
 }
 
-// tick is the actual tick action from the ticker that's called per interval
-func (h *Hotcache) tick() {
-	keylength := len(h.expiringKeys)
-	if keylength == 0 {
-		return
+// GetAndDelete retrieves a key that isn't expired from cache and removes it, so no other caller can retrieve it.
+// Expired keys are cleaned up and reported as missing.
+func (h *Hotcache) GetAndDelete(key string) (interface{}, bool) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	val, ok := s.store[key]
+	if !ok {
+		return nil, false
 	}
 
-	toCheck := 1000
-	if keylength < toCheck {
-		toCheck = keylength
+	if val.expired(h.now()) {
+		h.remove(s, key, val, ReasonExpired)
+		return nil, false
 	}
 
-	// Check random keys on the expiring keys lish.
-	for i := 0; i < toCheck; i++ {
-		rand.Seed(time.Now().UnixNano())
-		index := rand.Intn(len(h.expiringKeys))
-
-		// Race conditions
-		h.expiryMutex.RLock()
-		if len(h.expiringKeys) <= index {
-			// Check if key still in slice
-			h.expiryMutex.RUnlock()
-			continue
-		}
+	h.remove(s, key, val, ReasonDeleted)
+	if val.negative {
+		return nil, false
+	}
+	return val.value, true
+}
 
-		key := h.expiringKeys[index]
-		h.expiryMutex.RUnlock()
+// CompareAndDelete removes a key only if it exists, isn't expired and currently holds old, returning whether it was
+// removed. Values are compared the same way as CompareAndSwap.
+func (h *Hotcache) CompareAndDelete(key string, old interface{}) bool {
+	s := h.shard(key)
 
-		evicted := h.attemptEviction(key)
-		if evicted {
-			// Remove the key as an expiring key
-			h.expiryMutex.Lock()
-			h.expiringKeys[index] = h.expiringKeys[len(h.expiringKeys)-1]
-			h.expiringKeys = h.expiringKeys[:len(h.expiringKeys)-1]
-			h.expiryMutex.Unlock()
-		}
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	current, ok, _ := s.get(key, h.now())
+	if !ok || !valuesEqual(current, old) {
+		return false
 	}
+
+	h.remove(s, key, s.store[key], ReasonDeleted)
+	return true
 }
 
-// attemptEviction will attempt to evict the key if it has already expired.
-func (h *Hotcache) attemptEviction(key string) bool {
-	h.storeMutex.RLock()
-	value, ok := h.store[key]
-	h.storeMutex.RUnlock()
+// Rename moves a key's value to newKey, keeping the time it expires at, and returns true. It returns false if oldKey
+// is missing or expired. Any existing value at newKey is overwritten. The old key isn't passed to OnEvict as its value
+// is still in cache, but subscribers see it deleted and newKey set.
+func (h *Hotcache) Rename(oldKey, newKey string) bool {
+	from, to := h.shard(oldKey), h.shard(newKey)
 
-	if !ok || value.expiry.IsZero() {
+	h.lockStores(from, to)
+	defer h.unlockStores(from, to)
+
+	val, ok := from.store[oldKey]
+	if !ok || !val.live(h.now()) {
+		return false
+	}
+
+	if oldKey == newKey {
+		return true
+	}
+
+	h.detach(from, oldKey, val)
+	h.recordEvent(from, oldKey, EventDeleted, val.value)
+	h.setValue(to, newKey, val.copy())
+	return true
+}
+
+// DeletePrefix removes every key starting with prefix from cache, returning how many were removed. It has to check
+// every key in cache, so it's intended for occasional invalidation rather than hot paths.
+func (h *Hotcache) DeletePrefix(prefix string) int {
+	return h.deleteKeys(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// DeleteMatchingGlob removes every key matching a glob pattern from cache, returning how many were removed, see
+// KeysMatching for the syntax. It has to check every key in cache, so it's O(n) in the number of keys and intended for
+// occasional invalidation rather than hot paths.
+func (h *Hotcache) DeleteMatchingGlob(pattern string) int {
+	if !validGlob(pattern) {
+		return 0
+	}
+
+	return h.deleteKeys(func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	})
+}
+
+// DeleteMatching removes every key that hasn't expired and fn returns true for, returning how many were removed. fn is
+// called without holding any locks, so it's free to use the cache, and keys that are changed after fn was called with
+// them are left alone. It has to check every key in cache, so it's intended for occasional invalidation rather than
+// hot paths.
+func (h *Hotcache) DeleteMatching(fn func(key string, value interface{}) bool) int {
+	type candidate struct {
+		key string
+		val *cacheValue
+	}
+
+	deleted := 0
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.RLock()
+		candidates := make([]candidate, 0, len(s.store))
+		for key, val := range s.store {
+			if val.live(now) {
+				candidates = append(candidates, candidate{key: key, val: val})
+			}
+		}
+		s.storeMutex.RUnlock()
+
+		matched := candidates[:0]
+		for _, c := range candidates {
+			if fn(c.key, c.val.value) {
+				matched = append(matched, c)
+			}
+		}
+
+		if len(matched) == 0 {
+			continue
+		}
+
+		s.storeMutex.Lock()
+		for _, c := range matched {
+			// Values are replaced rather than modified, so if the key still holds the same value it hasn't changed
+			// since fn saw it.
+			if s.store[c.key] == c.val {
+				h.remove(s, c.key, c.val, ReasonDeleted)
+				deleted++
+			}
+		}
+		h.unlockStore(s)
+	}
+
+	return deleted
+}
+
+// deleteKeys removes every key that match returns true for, returning how many live keys were removed. Expired keys
+// that match are cleaned up but not counted. match is called with a shard's store mutex held, so it must not use the
+// cache.
+func (h *Hotcache) deleteKeys(match func(key string) bool) int {
+	deleted := 0
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.Lock()
+		for key, val := range s.store {
+			if !match(key) {
+				continue
+			}
+
+			if val.expired(now) {
+				h.remove(s, key, val, ReasonExpired)
+				continue
+			}
+
+			h.remove(s, key, val, ReasonDeleted)
+			if !val.negative {
+				deleted++
+			}
+		}
+		h.unlockStore(s)
+	}
+
+	return deleted
+}
+
+// Len returns the number of keys in cache that haven't expired.
+func (h *Hotcache) Len() int {
+	now := h.now()
+	count := 0
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for _, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			count++
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return count
+}
+
+// LenApprox returns the number of keys currently held in store, including expired keys that haven't been evicted
+// yet. It's cheaper than Len as it doesn't need to check every key.
+func (h *Hotcache) LenApprox() int {
+	return int(atomic.LoadInt64(&h.count))
+}
+
+// KeysMatching returns every key in cache that hasn't expired and matches a glob pattern, such as "session:*:temp", in
+// an unspecified order. Patterns follow path.Match: * matches any run of characters other than /, ? matches any single
+// character other than /, [abc] or [a-z] matches one character from a set or range, [^a-z] one that isn't, and \
+// escapes the next character. Malformed patterns match nothing. It has to check every key in cache, so it's O(n) in the
+// number of keys.
+func (h *Hotcache) KeysMatching(pattern string) []string {
+	if !validGlob(pattern) {
+		return nil
+	}
+
+	now := h.now()
+	var keys []string
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			if matched, _ := path.Match(pattern, key); matched {
+				keys = append(keys, key)
+			}
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return keys
+}
+
+// validGlob checks whether pattern is well formed, so a malformed one can be rejected before scanning every key.
+func validGlob(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// Keys returns every key in cache that hasn't expired. The order of the keys is unspecified.
+func (h *Hotcache) Keys() []string {
+	now := h.now()
+	keys := make([]string, 0, h.LenApprox())
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return keys
+}
+
+// randomKeyAttempts is how many stored keys RandomKey picks before falling back to picking from only the keys that
+// haven't expired.
+const randomKeyAttempts = 8
+
+// RandomKey returns a key picked uniformly at random from every key in cache that hasn't expired, or false if there
+// aren't any. Maps can't be indexed, so it walks the store up to the key it picks, which is linear in the size of a
+// shard.
+func (h *Hotcache) RandomKey() (string, bool) {
+	now := h.now()
+
+	// Picking again whenever the key has expired keeps the pick uniform between the keys that haven't.
+	for attempt := 0; attempt < randomKeyAttempts; attempt++ {
+		total := h.LenApprox()
+		if total <= 0 {
+			return "", false
+		}
+
+		key, val, ok := h.storedKeyAt(h.randIntn(total))
+		if ok && val.live(now) {
+			return key, true
+		}
+	}
+
+	// Most stored keys have expired, so pick from the ones that haven't instead.
+	keys := h.Keys()
+	if len(keys) == 0 {
+		return "", false
+	}
+	return keys[h.randIntn(len(keys))], true
+}
+
+// storedKeyAt returns the key at offset counting through every shard's store in turn, expired or not, or false if the
+// stores don't hold that many keys.
+func (h *Hotcache) storedKeyAt(offset int) (string, *cacheValue, bool) {
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		if offset >= len(s.store) {
+			offset -= len(s.store)
+			s.storeMutex.RUnlock()
+			continue
+		}
+
+		for key, val := range s.store {
+			if offset == 0 {
+				s.storeMutex.RUnlock()
+				return key, val, true
+			}
+			offset--
+		}
+		s.storeMutex.RUnlock()
+	}
+	return "", nil, false
+}
+
+// Range calls fn for every key in cache that hasn't expired, stopping early if fn returns false. The order of the
+// keys is unspecified.
+//
+// Each shard is snapshotted before fn is called with its keys, so it's safe to use the cache from within fn, but
+// changes made while ranging may or may not be seen.
+func (h *Hotcache) Range(fn func(key string, value interface{}) bool) {
+	type entry struct {
+		key   string
+		value interface{}
+	}
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.RLock()
+		entries := make([]entry, 0, len(s.store))
+		for key, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			entries = append(entries, entry{key: key, value: val.value})
+		}
+		s.storeMutex.RUnlock()
+
+		for _, e := range entries {
+			if !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// ForEachExpiring calls fn with every key in cache that has an expiry and hasn't expired yet, along with when it
+// expires. Like Range, each shard is copied before fn is called with its keys, so fn is free to use the cache.
+func (h *Hotcache) ForEachExpiring(fn func(key string, expiresAt time.Time)) {
+	type entry struct {
+		key       string
+		expiresAt time.Time
+	}
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.RLock()
+		s.expiryMutex.RLock()
+		entries := make([]entry, 0, len(s.expiringKeys))
+		for _, key := range s.expiringKeys {
+			val, ok := s.store[key]
+			if !ok || val.expiry.IsZero() || !val.live(now) {
+				continue
+			}
+			entries = append(entries, entry{key: key, expiresAt: val.expiry})
+		}
+		s.expiryMutex.RUnlock()
+		s.storeMutex.RUnlock()
+
+		for _, e := range entries {
+			fn(e.key, e.expiresAt)
+		}
+	}
+}
+
+// now returns the current time from the cache's clock.
+func (h *Hotcache) now() time.Time {
+	return h.options.clock.Now()
+}
+
+// shard returns the shard a key belongs to.
+func (h *Hotcache) shard(key string) *shard {
+	if len(h.shards) == 1 {
+		return h.shards[0]
+	}
+	return h.shards[shardIndex(key, len(h.shards))]
+}
+
+// groupByShard splits keys by the shard they belong to, indexed the same as h.shards.
+func (h *Hotcache) groupByShard(keys []string) [][]string {
+	groups := make([][]string, len(h.shards))
+	for _, key := range keys {
+		i := shardIndex(key, len(h.shards))
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+// set assumes that the store mutex lock has already been obtained, the expiry mutex is obtained as needed.
+func (h *Hotcache) set(s *shard, key string, value interface{}, expiration time.Duration) {
+	h.setValue(s, key, h.newValue(value, expiration))
+}
+
+// setValue stores an already wrapped value and tracks its expiry, assumes the store mutex is held.
+func (h *Hotcache) setValue(s *shard, key string, val *cacheValue) {
+	h.put(s, key, val)
+
+	if !val.expiry.IsZero() {
+		s.trackExpiry(key)
+	}
+}
+
+// put stores a value, replacing any existing value. It doesn't track the value's expiry, callers must add the key to
+// expiringKeys if the value has one. Assumes the store mutex is held.
+func (h *Hotcache) put(s *shard, key string, val *cacheValue) {
+	if old, ok := s.store[key]; ok {
+		reason := ReasonReplaced
+		if old.expired(h.now()) {
+			reason = ReasonExpired
+		}
+		h.recordEviction(s, key, old.value, reason)
+		if reason == ReasonExpired && !old.negative {
+			h.recordEvent(s, key, EventExpired, old.value)
+		}
+		atomic.AddInt64(&h.cost, -old.cost)
+
+		// Callers track the new value's expiry, but one that loses its expiry has to stop being tracked here.
+		if !old.expiry.IsZero() && val.expiry.IsZero() {
+			s.untrackExpiry(key)
+		}
+	} else {
+		atomic.AddInt64(&h.count, 1)
+		if h.bloom != nil {
+			h.bloom.add(key)
+		}
+	}
+	atomic.AddInt64(&h.cost, val.cost)
+
+	// New values start counting their uses from zero, renamed values carry on with their counts.
+	if h.options.accessTracking && val.access == nil {
+		val.access = &keyAccess{}
+	}
+
+	s.store[key] = val
+	h.scheduleExpiry(s, key, val)
+	atomic.AddUint64(&h.stats.sets, 1)
+	if !val.negative {
+		h.recordEvent(s, key, EventSet, val.value)
+	}
+
+	if h.tracker != nil {
+		h.tracker.add(key)
+	}
+}
+
+// SetNX sets a key only if it doesn't already exist or has expired, returning whether it was set.
+func (h *Hotcache) SetNX(key string, value interface{}, expiration time.Duration) bool {
+	if h.tooLarge(value) {
+		return false
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	_, exists, _ := s.get(key, h.now())
+	if exists {
+		return false
+	}
+
+	h.set(s, key, value, expiration)
+	return true
+}
+
+// SetNXWithResult is SetNX that also returns the key's value, which is value if it was set, or the existing value if
+// it wasn't. It's the same as GetOrSet with the bool reversed.
+func (h *Hotcache) SetNXWithResult(key string, value interface{}, expiration time.Duration) (actual interface{}, set bool) {
+	if h.tooLarge(value) {
+		actual, _ = h.Get(key)
+		return actual, false
+	}
+
+	actual, existed := h.GetOrSet(key, value, expiration)
+	return actual, !existed
+}
+
+// PriorState describes what SetIfVacant found for a key before setting it.
+type PriorState int
+
+const (
+	// PriorAbsent means the key wasn't in cache, or was cached as missing with SetMissing.
+	PriorAbsent PriorState = iota
+	// PriorExpired means the key had expired but hadn't been evicted yet.
+	PriorExpired
+	// PriorPresent means the key was in cache and hadn't expired.
+	PriorPresent
+)
+
+// String returns the name of the state.
+func (p PriorState) String() string {
+	switch p {
+	case PriorAbsent:
+		return "absent"
+	case PriorExpired:
+		return "expired"
+	case PriorPresent:
+		return "present"
+	default:
+		return "unknown"
+	}
+}
+
+// SetIfVacant is SetNX that also reports what it found for the key, to tell apart a key that was never set from one
+// that had expired, or to log why it wasn't set. The key is only set if the prior state isn't PriorPresent.
+func (h *Hotcache) SetIfVacant(key string, value interface{}, expiration time.Duration) (set bool, prior PriorState) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	_, exists, expired := s.get(key, h.now())
+	switch {
+	case exists:
+		return false, PriorPresent
+	case expired:
+		prior = PriorExpired
+	default:
+		prior = PriorAbsent
+	}
+	if h.tooLarge(value) {
+		return false, prior
+	}
+
+	h.set(s, key, value, expiration)
+	return true, prior
+}
+
+// LoadOrStore matches sync.Map's LoadOrStore, to ease moving from it. It returns the existing value if the key exists
+// and isn't expired, otherwise it stores value without an expiry and returns it. The bool reports whether the value
+// was loaded rather than stored.
+func (h *Hotcache) LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool) {
+	return h.GetOrSet(key, value, NoExpiry)
+}
+
+// LoadOrStoreWithTTL is LoadOrStore that stores value with the given expiration.
+func (h *Hotcache) LoadOrStoreWithTTL(key string, value interface{}, expiration time.Duration) (actual interface{}, loaded bool) {
+	return h.GetOrSet(key, value, expiration)
+}
+
+// Replace sets a key only if it already exists and isn't expired, returning whether it was set.
+func (h *Hotcache) Replace(key string, value interface{}, expiration time.Duration) bool {
+	if h.tooLarge(value) {
+		return false
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	_, exists, _ := s.get(key, h.now())
+	if !exists {
+		return false
+	}
+
+	h.set(s, key, value, expiration)
+	return true
+}
+
+// SetIfGreater sets a key only if it's missing or expired, or version is greater than the version of its existing
+// value, returning whether it was set. This keeps the newest value when versioned writes arrive out of order. Values
+// set by anything other than SetIfGreater have a version of 0.
+func (h *Hotcache) SetIfGreater(key string, value interface{}, version int64, expiration time.Duration) bool {
+	if h.tooLarge(value) {
+		return false
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	if old, ok := s.store[key]; ok && old.live(h.now()) && version <= old.version {
+		return false
+	}
+
+	val := h.newValue(value, expiration)
+	val.version = version
+	h.setValue(s, key, val)
+	return true
+}
+
+// GetOrSet returns the value of a key if it exists, otherwise value is set and returned. The bool reports whether the
+// key already existed. Unlike calling Get then SetNX, concurrent callers can't both miss and both set.
+func (h *Hotcache) GetOrSet(key string, value interface{}, expiration time.Duration) (interface{}, bool) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	existing, ok, _ := s.get(key, h.now())
+	if ok {
+		return existing, true
+	}
+
+	if !h.tooLarge(value) {
+		h.set(s, key, value, expiration)
+	}
+	return value, false
+}
+
+// GetOrSetFunc is GetOrSet that only calls fn to build the value once the key is found missing, for values that are
+// costly to build but can't fail to. fn is called while the key's shard is locked, so it runs at most once for
+// concurrent callers missing on the same key, but it must not use the cache and holds up other keys in the shard until
+// it returns. Use GetOrCompute for slow or fallible loads.
+func (h *Hotcache) GetOrSetFunc(key string, expiration time.Duration, fn func() interface{}) interface{} {
+	if val, ok := h.Get(key); ok {
+		return val
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	// Another caller may have set the key between our lookup and obtaining the lock.
+	if existing, ok, _ := s.get(key, h.now()); ok {
+		return existing
+	}
+
+	value := fn()
+	if !h.tooLarge(value) {
+		h.set(s, key, value, expiration)
+	}
+	return value
+}
+
+// Swap sets a key and returns its previous value, the bool reports whether the key existed and wasn't expired. The
+// read and write happen under one lock, so no other write can slip in between them.
+func (h *Hotcache) Swap(key string, value interface{}, expiration time.Duration) (interface{}, bool) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, existed, _ := s.get(key, h.now())
+	if !h.tooLarge(value) {
+		h.set(s, key, value, expiration)
+	}
+	return old, existed
+}
+
+// CompareAndSwap sets a key to new only if it exists, isn't expired and currently holds old, returning whether it was
+// set. See valuesEqual for how values are compared.
+func (h *Hotcache) CompareAndSwap(key string, old, new interface{}, expiration time.Duration) bool {
+	if h.tooLarge(new) {
+		return false
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	current, ok, _ := s.get(key, h.now())
+	if !ok || !valuesEqual(current, old) {
+		return false
+	}
+
+	h.set(s, key, new, expiration)
+	return true
+}
+
+// Update atomically replaces a key with the result of fn, which is called with the key's current value and whether it
+// exists and isn't expired. fn returns the value to store along with its expiration, which works the same as Set's, or
+// keep as false to delete the key instead. The key's shard stays locked while fn runs, so fn must not use the cache.
+// Values returned by Get may still be in use elsewhere, so fn should return a modified copy of values like maps rather
+// than changing them in place.
+func (h *Hotcache) Update(key string, fn func(old interface{}, exists bool) (new interface{}, ttl time.Duration, keep bool)) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists, _ := s.get(key, h.now())
+	value, ttl, keep := fn(old, exists)
+	if keep {
+		if !h.tooLarge(value) {
+			h.set(s, key, value, ttl)
+		}
+		return
+	}
+
+	if val, ok := s.store[key]; ok {
+		if val.expired(h.now()) {
+			h.evict(s, key)
+		} else {
+			h.remove(s, key, val, ReasonDeleted)
+		}
+	}
+}
+
+// valuesEqual compares values with == when they're of the same comparable type, so pointers match only if they point
+// to the same thing. Values that can't be compared with ==, such as slices and maps, fall back to reflect.DeepEqual.
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	typ := reflect.TypeOf(a)
+	if typ != reflect.TypeOf(b) {
+		return false
+	}
+
+	if typ.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// evict removes a key from cache that has expired, once it's past the window set by WithStaleWindow. Assumes a mutex
+// is held.
+func (h *Hotcache) evict(s *shard, key string) {
+	// The key may have been set again between the caller releasing its read lock and obtaining the write lock, so
+	// only remove it if it's still expired.
+	if val, ok := s.store[key]; ok && h.evictable(val, h.now()) {
+		h.remove(s, key, val, ReasonExpired)
+	}
+}
+
+// remove deletes a key from store along with any tracking of it and reports it as evicted, assumes the store mutex is
+// held.
+func (h *Hotcache) remove(s *shard, key string, val *cacheValue, reason EvictReason) {
+	h.detach(s, key, val)
+	h.recordEviction(s, key, val.value, reason)
+
+	if typ, ok := eventType(reason); ok && !val.negative {
+		h.recordEvent(s, key, typ, val.value)
+	}
+}
+
+// detach deletes a key from store along with any tracking of it, without reporting it as evicted. Assumes the store
+// mutex is held.
+func (h *Hotcache) detach(s *shard, key string, val *cacheValue) {
+	delete(s.store, key)
+	if !val.expiry.IsZero() {
+		s.untrackExpiry(key)
+	}
+	atomic.AddInt64(&h.count, -1)
+	atomic.AddInt64(&h.cost, -val.cost)
+	if h.tracker != nil {
+		h.tracker.remove(key)
+	}
+	if h.bloom != nil {
+		atomic.AddInt64(&h.bloom.removed, 1)
+	}
+}
+
+// access marks a key as used. The eviction tracker has its own lock and access counts are atomic, so no store mutex
+// needs to be held.
+func (h *Hotcache) access(key string, val *cacheValue) {
+	if h.tracker != nil {
+		h.tracker.access(key)
+	}
+	h.recordAccess(val)
+}
+
+// overBounds checks whether the cache holds more keys or more cost than it's been bounded to.
+func (h *Hotcache) overBounds() bool {
+	if maxKeys := atomic.LoadInt64(&h.maxKeys); maxKeys > 0 && atomic.LoadInt64(&h.count) > maxKeys {
+		return true
+	}
+	return h.options.maxCost > 0 && atomic.LoadInt64(&h.cost) > h.options.maxCost
+}
+
+// enforceBounds evicts keys chosen by the eviction policy until the cache is within its bounds, returning how many it
+// evicted. The key to evict may live in any shard, so this must be called without holding a store mutex.
+func (h *Hotcache) enforceBounds() int {
+	evicted := 0
+	for h.overBounds() {
+		key, ok := h.tracker.victim()
+		if !ok {
+			return evicted
+		}
+
+		s := h.shard(key)
+		s.storeMutex.Lock()
+		if val, ok := s.store[key]; ok {
+			h.remove(s, key, val, ReasonCapacity)
+			evicted++
+		} else {
+			h.tracker.remove(key)
+		}
+		if h.options.readOptimized {
+			s.publishSnapshot()
+		}
+		evictions, events := s.takeEvictions(), s.takeEvents()
+		s.storeMutex.Unlock()
+
+		h.notifyEvictions(evictions)
+		h.publish(events)
+	}
+	return evicted
+}
+
+// startTicker starts the ticking process for garbage collection on it's own goroutine, returning once done is closed.
+// Stopping a ticker doesn't close its channel, so ranging over it would block forever after Stop.
+func (h *Hotcache) startTicker(ticker *time.Ticker, done <-chan struct{}) {
+	defer h.background.Done()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.tick()
+		case <-done:
+			return
+		}
+	}
+}
+
+// tick is the actual tick action from the ticker that's called per interval
+func (h *Hotcache) tick() {
+	// Split the batch between every shard, as keys are evenly distributed between them.
+	toCheck := (h.options.gcBatchSize + len(h.shards) - 1) / len(h.shards)
+
+	rounds, totalChecked, totalEvicted := 0, 0, 0
+	if h.options.wheelResolution > 0 {
+		rounds = 1
+		now := h.now()
+		for _, s := range h.shards {
+			c, e := h.tickWheel(s, now)
+			totalChecked += c
+			totalEvicted += e
+		}
+	} else {
+		for {
+			rounds++
+			checked, evicted := 0, 0
+			for _, s := range h.shards {
+				c, e := h.tickShard(s, toCheck)
+				checked += c
+				evicted += e
+			}
+			totalChecked += checked
+			totalEvicted += evicted
+
+			// With adaptive GC, batches that are mostly expired keys suggest there's more to clean up, so keep going.
+			if !h.options.adaptiveGC || rounds >= adaptiveGCMaxRounds || evicted*4 <= checked {
+				break
+			}
+		}
+	}
+
+	if h.debugEnabled() {
+		h.debug("hotcache: gc tick",
+			slog.Int("rounds", rounds),
+			slog.Int("checked", totalChecked),
+			slog.Int("evicted", totalEvicted),
+		)
+	}
+
+	if h.options.refreshLoader != nil {
+		for _, s := range h.shards {
+			h.refreshAhead(s)
+		}
+	}
+
+	if h.bloom != nil && h.bloomStale() {
+		h.rebuildBloom()
+	}
+
+	h.options.metrics.ObserveSize(h.LenApprox())
+}
+
+// tickShard checks up to toCheck random expiring keys in a shard, evicting any that have expired. It returns how many
+// keys it checked and how many of those were evicted.
+func (h *Hotcache) tickShard(s *shard, toCheck int) (checked int, evicted int) {
+	s.expiryMutex.RLock()
+	keylength := len(s.expiringKeys)
+	s.expiryMutex.RUnlock()
+
+	if keylength == 0 {
+		return 0, 0
+	}
+
+	if keylength < toCheck {
+		toCheck = keylength
+	}
+
+	// Check random keys on the expiring keys lish.
+	for i := 0; i < toCheck; i++ {
+		// Race conditions, the slice may have been cleared since we last looked at it.
+		s.expiryMutex.RLock()
+		if len(s.expiringKeys) == 0 {
+			s.expiryMutex.RUnlock()
+			return checked, evicted
+		}
+
+		index := h.randIntn(len(s.expiringKeys))
+		key := s.expiringKeys[index]
+		s.expiryMutex.RUnlock()
+
+		// Evicting the key removes it from expiringKeys, as does attemptEviction for keys that are missing or lost
+		// their expiry. It isn't untracked again here, as it may have been set again since.
+		checked++
+		if h.attemptEviction(s, key) {
+			evicted++
+		}
+	}
+
+	return checked, evicted
+}
+
+// expiryFor returns when a key set now with expiration expires, the zero time meaning it doesn't. NoExpiry is never,
+// and so is 0 unless WithZeroTTLMeansImmediate is set, in which case it's now.
+func (h *Hotcache) expiryFor(expiration time.Duration) time.Time {
+	switch {
+	case expiration == NoExpiry:
+		return time.Time{}
+	case expiration == 0 && h.options.zeroTTLImmediate:
+		return h.now()
+	case expiration == 0:
+		return time.Time{}
+	default:
+		return h.expiresAt(expiration)
+	}
+}
+
+// expiresAt returns when a key set now with ttl expires, adding a random jitter when WithExpiryJitter is set.
+func (h *Hotcache) expiresAt(ttl time.Duration) time.Time {
+	expiry := h.now().Add(ttl)
+	if h.options.expiryJitter > 0 && ttl > 0 {
+		expiry = expiry.Add(time.Duration(h.randInt63n(int64(h.options.expiryJitter))))
+	}
+	return expiry
+}
+
+// randIntn returns a random number in [0, n) from the cache's random source, which isn't safe for concurrent use on
+// its own.
+func (h *Hotcache) randIntn(n int) int {
+	h.randMutex.Lock()
+	defer h.randMutex.Unlock()
+
+	return h.rand.Intn(n)
+}
+
+// randInt63n is randIntn for int64s.
+func (h *Hotcache) randInt63n(n int64) int64 {
+	h.randMutex.Lock()
+	defer h.randMutex.Unlock()
+
+	return h.rand.Int63n(n)
+}
+
+// attemptEviction will attempt to evict the key if it has already expired, and stops tracking it if it's missing or
+// doesn't expire.
+func (h *Hotcache) attemptEviction(s *shard, key string) bool {
+	s.storeMutex.RLock()
+	value, ok := s.store[key]
+	s.storeMutex.RUnlock()
+
+	if !ok || value.expiry.IsZero() {
+		// Keys are untracked as they're removed or lose their expiry, so this shouldn't happen, but drop the key so it's
+		// never checked again. It's checked again under the lock, as it may have been set with an expiry since.
+		s.storeMutex.Lock()
+		if value, ok := s.store[key]; !ok || value.expiry.IsZero() {
+			s.untrackExpiry(key)
+		}
+		s.storeMutex.Unlock()
 		return true // We can say it's evicted as this will never expiry anyway
 	}
 
-	if value.expiry.After(time.Now()) {
+	if !h.evictable(value, h.now()) {
 		return false
 	}
 
-	h.storeMutex.Lock()
-	delete(h.store, key)
-	h.storeMutex.Unlock()
+	s.storeMutex.Lock()
+	h.evict(s, key)
+	h.unlockStore(s)
 
 	return true
 }
@@ -1,12 +1,74 @@
 package hotcache
 
 import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aidenwallis/hotcache/hotcachetest"
 	"github.com/stretchr/testify/assert"
 )
 
+// newFakeClock returns a fake clock so expiry tests can advance time rather than sleep.
+func newFakeClock() *hotcachetest.FakeClock {
+	return hotcachetest.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// expiringKeyCount returns the number of keys tracked as expiring across every shard.
+func expiringKeyCount(h *Hotcache) int {
+	count := 0
+	for _, s := range h.shards {
+		s.expiryMutex.RLock()
+		count += len(s.expiringKeys)
+		s.expiryMutex.RUnlock()
+	}
+	return count
+}
+
+// eventually checks condition every millisecond until it's true, failing the test if it isn't within a second. This
+// is used rather than assert.Eventually, which runs each check on its own goroutine and panics if one finishes after
+// it's returned.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("condition never satisfied")
+}
+
+// assertExpiryTracked checks that every shard tracks exactly the keys in its store that have an expiry.
+func assertExpiryTracked(t *testing.T, h *Hotcache) {
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		s.expiryMutex.RLock()
+
+		expected := make(map[string]int)
+		for key, val := range s.store {
+			if !val.expiry.IsZero() {
+				expected[key] = s.expiringIndex[key]
+			}
+		}
+		assert.Equal(t, s.expiringIndex, expected)
+		assert.Equal(t, len(s.expiringKeys), len(s.expiringIndex))
+		for index, key := range s.expiringKeys {
+			assert.Equal(t, s.expiringIndex[key], index)
+		}
+
+		s.expiryMutex.RUnlock()
+		s.storeMutex.RUnlock()
+	}
+}
+
 func TestGetNonexistent(t *testing.T) {
 	cache := New()
 	defer cache.Stop()
@@ -25,7 +87,8 @@ func TestGetExists(t *testing.T) {
 }
 
 func TestExpiry(t *testing.T) {
-	cache := New()
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
 	defer cache.Stop()
 
 	cache.Set("xd", "xd", time.Millisecond*10)
@@ -34,7 +97,7 @@ func TestExpiry(t *testing.T) {
 	assert.Equal(t, val, "xd")
 	assert.Equal(t, ok, true)
 
-	time.Sleep(time.Millisecond * 10)
+	clock.Advance(time.Millisecond * 10)
 
 	val, ok = cache.Get("xd")
 	assert.Equal(t, val, nil)
@@ -58,6 +121,87 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, ok, false)
 }
 
+func TestDeleteIfExists(t *testing.T) {
+	clock := newFakeClock()
+	rec := &evictionRecorder{}
+	cache := New(WithClock(clock), WithOnEvict(rec.onEvict))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.DeleteIfExists("xd"), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.DeleteIfExists("xd"), true)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.DeleteIfExists("xd"), false)
+
+	cache.Set("xd2", "xd2", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+	assert.Equal(t, cache.DeleteIfExists("xd2"), false)
+	assert.Equal(t, cache.LenApprox(), 0)
+
+	cache.SetMissing("xd3", 0)
+	assert.Equal(t, cache.DeleteIfExists("xd3"), false)
+	assert.Equal(t, cache.LenApprox(), 0)
+
+	assert.Equal(t, rec.get(), []eviction{
+		{"xd", "xd", ReasonDeleted},
+		{"xd2", "xd2", ReasonExpired},
+		{"xd3", nil, ReasonDeleted},
+	})
+	assertExpiryTracked(t, cache)
+}
+
+func TestRandomKey(t *testing.T) {
+	cache := New(WithRandSource(rand.NewSource(1)))
+	defer cache.Stop()
+
+	_, ok := cache.RandomKey()
+	assert.Equal(t, ok, false)
+
+	keys := map[string]int{}
+	for i := 0; i < 4; i++ {
+		key := strconv.Itoa(i)
+		cache.Set(key, i, 0)
+		keys[key] = 0
+	}
+
+	for i := 0; i < 4000; i++ {
+		key, ok := cache.RandomKey()
+		assert.Equal(t, ok, true)
+		_, member := keys[key]
+		assert.True(t, member, key)
+		keys[key]++
+	}
+
+	// Every key is picked about as often as the others.
+	for key, picked := range keys {
+		assert.True(t, picked > 800 && picked < 1200, "%s picked %d times", key, picked)
+	}
+}
+
+func TestRandomKeySkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithStartPaused(true))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Millisecond)
+	}
+	cache.SetMissing("missing", 0)
+	cache.Set("xd", "xd", 0)
+	clock.Advance(time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		key, ok := cache.RandomKey()
+		assert.Equal(t, key, "xd")
+		assert.Equal(t, ok, true)
+	}
+
+	cache.Delete("xd")
+	_, ok := cache.RandomKey()
+	assert.Equal(t, ok, false)
+}
+
 func TestHas(t *testing.T) {
 	cache := New()
 	defer cache.Stop()
@@ -69,7 +213,8 @@ func TestHas(t *testing.T) {
 }
 
 func TestHasExpiry(t *testing.T) {
-	cache := New()
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
 	defer cache.Stop()
 
 	cache.Set("xd", "xd", time.Millisecond*10)
@@ -77,7 +222,7 @@ func TestHasExpiry(t *testing.T) {
 	exists := cache.Has("xd")
 	assert.Equal(t, exists, true)
 
-	time.Sleep(time.Millisecond * 10)
+	clock.Advance(time.Millisecond * 10)
 
 	exists = cache.Has("xd")
 	assert.Equal(t, exists, false)
@@ -99,7 +244,8 @@ func TestSetNX(t *testing.T) {
 }
 
 func TestSetNXExpiry(t *testing.T) {
-	cache := New()
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
 	defer cache.Stop()
 
 	set := cache.SetNX("xd", "xd", time.Millisecond*10)
@@ -112,7 +258,7 @@ func TestSetNXExpiry(t *testing.T) {
 	set = cache.SetNX("xd", "xd", 0)
 	assert.Equal(t, set, false)
 
-	time.Sleep(time.Millisecond * 10)
+	clock.Advance(time.Millisecond * 10)
 
 	set = cache.SetNX("xd", "xd2", 0)
 	assert.Equal(t, set, true)
@@ -121,3 +267,1683 @@ func TestSetNXExpiry(t *testing.T) {
 	assert.Equal(t, val, "xd2")
 	assert.Equal(t, ok, true)
 }
+
+func TestSetNXWithResult(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	actual, set := cache.SetNXWithResult("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, actual, "xd")
+	assert.Equal(t, set, true)
+
+	actual, set = cache.SetNXWithResult("xd", "xd2", 0)
+	assert.Equal(t, actual, "xd")
+	assert.Equal(t, set, false)
+
+	clock.Advance(time.Millisecond * 10)
+
+	actual, set = cache.SetNXWithResult("xd", "xd3", 0)
+	assert.Equal(t, actual, "xd3")
+	assert.Equal(t, set, true)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd3")
+}
+
+func TestSetIfVacant(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	set, prior := cache.SetIfVacant("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, set, true)
+	assert.Equal(t, prior, PriorAbsent)
+
+	set, prior = cache.SetIfVacant("xd", "xd2", 0)
+	assert.Equal(t, set, false)
+	assert.Equal(t, prior, PriorPresent)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+
+	clock.Advance(time.Millisecond * 10)
+
+	set, prior = cache.SetIfVacant("xd", "xd3", 0)
+	assert.Equal(t, set, true)
+	assert.Equal(t, prior, PriorExpired)
+
+	val, _ = cache.Get("xd")
+	assert.Equal(t, val, "xd3")
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetIfVacantMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.SetMissing("xd", 0)
+
+	set, prior := cache.SetIfVacant("xd", "xd", 0)
+	assert.Equal(t, set, true)
+	assert.Equal(t, prior, PriorAbsent)
+}
+
+func TestPriorStateString(t *testing.T) {
+	assert.Equal(t, PriorAbsent.String(), "absent")
+	assert.Equal(t, PriorExpired.String(), "expired")
+	assert.Equal(t, PriorPresent.String(), "present")
+	assert.Equal(t, PriorState(-1).String(), "unknown")
+}
+
+func TestLoadOrStore(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	actual, loaded := cache.LoadOrStore("xd", "xd")
+	assert.Equal(t, actual, "xd")
+	assert.Equal(t, loaded, false)
+
+	actual, loaded = cache.LoadOrStore("xd", "xd2")
+	assert.Equal(t, actual, "xd")
+	assert.Equal(t, loaded, true)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+}
+
+func TestLoadOrStoreWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	actual, loaded := cache.LoadOrStoreWithTTL("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, actual, "xd")
+	assert.Equal(t, loaded, false)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*10)
+
+	// Expired keys are treated as absent.
+	clock.Advance(time.Millisecond * 10)
+
+	actual, loaded = cache.LoadOrStoreWithTTL("xd", "xd2", 0)
+	assert.Equal(t, actual, "xd2")
+	assert.Equal(t, loaded, false)
+}
+
+func TestLen(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Len(), 0)
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Set("xd3", "xd", time.Millisecond*10)
+	cache.Set("xd4", "xd", time.Second)
+
+	assert.Equal(t, cache.Len(), 4)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Len(), 3)
+}
+
+func TestLenApprox(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", time.Millisecond*10)
+
+	assert.Equal(t, cache.LenApprox(), 2)
+
+	clock.Advance(time.Millisecond * 10)
+
+	// Expired keys are still counted until they're evicted.
+	assert.Equal(t, cache.Len(), 1)
+	assert.Equal(t, cache.LenApprox(), 2)
+}
+
+func TestKeys(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Keys(), []string{})
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", time.Second)
+	cache.Set("xd3", "xd", time.Millisecond*10)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd2", "xd3"})
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd2"})
+}
+
+func TestClone(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	shared := []string{"xd"}
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", shared, time.Second)
+	cache.Set("expired", "xd", time.Millisecond)
+	clock.Advance(time.Millisecond)
+
+	clone := cache.Clone()
+	defer clone.Stop()
+
+	assert.ElementsMatch(t, clone.Keys(), []string{"xd", "xd2"})
+	assert.Equal(t, clone.LenApprox(), 2)
+
+	ttl, _ := clone.TTL("xd2")
+	assert.Equal(t, ttl, time.Second-time.Millisecond)
+	assertExpiryTracked(t, clone)
+
+	// The stores are independent, but values are shared.
+	cache.Delete("xd")
+	clone.Set("xd3", "xd", 0)
+
+	assert.Equal(t, clone.Has("xd"), true)
+	assert.Equal(t, cache.Has("xd3"), false)
+
+	val, _ := clone.Get("xd2")
+	assert.Equal(t, val, shared)
+}
+
+func TestClear(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", time.Second)
+
+	cache.Clear()
+
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Has("xd2"), false)
+	assert.Equal(t, expiringKeyCount(cache), 0)
+
+	// The cache must still be usable after clearing it.
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// The ticker should still be running and collect the expired key.
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestStop(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTickInterval(time.Millisecond))
+
+	cache.Set("xd", "xd", 0)
+	cache.Stop()
+	assert.Equal(t, cache.LenApprox(), 0)
+
+	// The cache can still be used, but the ticker isn't running, so an expired key is left until it's looked up.
+	cache.Set("xd", "xd", time.Second)
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	// Stopping a stopped cache does nothing, so the key isn't flushed.
+	cache.Stop()
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	_, ok = cache.Get("xd")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestStart(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTickInterval(time.Millisecond))
+	defer cache.Stop()
+
+	// Starting a running cache does nothing.
+	cache.Start()
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	cache.Stop()
+	cache.Start()
+
+	// The ticker is running again, so it collects the expired key.
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Second)
+	eventually(t, func() bool {
+		return cache.LenApprox() == 0
+	})
+}
+
+func TestStopGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		cache := New(WithTickInterval(time.Millisecond), WithStatsInterval(time.Millisecond, func(Stats) {}))
+		cache.Set("xd", "xd", time.Second)
+		cache.Stop()
+	}
+
+	// Goroutines can take a moment to exit after Stop returns.
+	eventually(t, func() bool {
+		return runtime.NumGoroutine() <= baseline
+	})
+}
+
+func TestClearDuringTick(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			cache.tick()
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		cache.Set("xd", "xd", time.Nanosecond)
+		cache.Clear()
+	}
+
+	<-done
+}
+
+func BenchmarkTick(b *testing.B) {
+	cache := New()
+	defer cache.Stop()
+
+	for i := 0; i < 10000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.tick()
+	}
+}
+
+func TestGetWithExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", time.Second)
+
+	val, expiresAt, ok := cache.GetWithExpiry("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, expiresAt, time.Time{})
+	assert.Equal(t, ok, true)
+
+	val, expiresAt, ok = cache.GetWithExpiry("xd2")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, expiresAt, clock.Now().Add(time.Second))
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+
+	val, expiresAt, ok = cache.GetWithExpiry("xd2")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, expiresAt, time.Time{})
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	_, _, ok = cache.GetWithExpiry("missing")
+	assert.Equal(t, ok, false)
+}
+
+func TestPeek(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	val, ok := cache.Peek("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	val, ok = cache.Peek("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.Stats(), Stats{Sets: 1})
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, ok = cache.Peek("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+
+	cache.SetMissing("xd", 0)
+	_, ok = cache.Peek("xd")
+	assert.Equal(t, ok, false)
+}
+
+func BenchmarkReadOptimized(b *testing.B) {
+	for _, readOptimized := range []bool{false, true} {
+		for _, goroutines := range []int{8, 64, 256} {
+			b.Run(fmt.Sprintf("readOptimized=%t/goroutines=%d", readOptimized, goroutines), func(b *testing.B) {
+				cache := New(WithReadOptimized(readOptimized))
+				defer cache.Stop()
+
+				keys := make([]string, 1024)
+				for i := range keys {
+					keys[i] = strconv.Itoa(i)
+					cache.Set(keys[i], i, 0)
+				}
+
+				b.SetParallelism(goroutines)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := 0
+					for pb.Next() {
+						cache.Get(keys[i%len(keys)])
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Duration(0))
+	assert.Equal(t, ok, false)
+
+	cache.Set("xd", "xd", 0)
+
+	ttl, ok = cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+}
+
+func TestTTLExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ok, true)
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+
+	cache.Set("xd2", "xd", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	ttl, ok = cache.TTL("xd2")
+	assert.Equal(t, ttl, time.Duration(0))
+	assert.Equal(t, ok, false)
+}
+
+func TestExpire(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Expire("xd", time.Second), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Expire("xd", time.Millisecond*10), true)
+	assert.Equal(t, expiringKeyCount(cache), 1)
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ok, true)
+	assert.True(t, ttl > 0 && ttl <= time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Expire("xd", time.Second), false)
+}
+
+func TestExpireExtend(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, cache.Expire("xd", time.Second), true)
+
+	// Already expiring keys shouldn't be tracked twice.
+	assert.Equal(t, expiringKeyCount(cache), 1)
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+}
+
+func TestExpireRemove(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	assert.Equal(t, cache.Expire("xd", 0), true)
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestExpireAt(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithExpiryJitter(time.Hour))
+	defer cache.Stop()
+
+	exp := clock.Now().Add(time.Second + time.Nanosecond*123)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.ExpireAt("xd", exp), true)
+	assert.Equal(t, cache.ExpireAt("xd2", exp), false)
+	assertExpiryTracked(t, cache)
+
+	// The expiry is exact, it isn't jittered or rounded.
+	_, expiresAt, _ := cache.GetWithExpiry("xd")
+	assert.Equal(t, expiresAt, exp)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.Has("xd"), true)
+
+	clock.Advance(time.Nanosecond * 123)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.ExpireAt("xd", exp.Add(time.Hour)), false)
+}
+
+func TestExpireAtPast(t *testing.T) {
+	clock := newFakeClock()
+	var reasons []EvictReason
+	cache := New(WithClock(clock), WithOnEvict(func(_ string, _ interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Hour)
+	assert.Equal(t, cache.ExpireAt("xd", clock.Now().Add(-time.Second)), true)
+
+	// The key is expired straight away rather than left for the garbage collector.
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, reasons, []EvictReason{ReasonExpired})
+	assertExpiryTracked(t, cache)
+}
+
+func TestExpireAtZero(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	assert.Equal(t, cache.ExpireAt("xd", time.Time{}), true)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache)
+}
+
+func TestPersist(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Set("xd2", "xd", 0)
+
+	assert.Equal(t, cache.Persist("xd"), true)
+	assert.Equal(t, cache.Persist("xd2"), true)
+	assert.Equal(t, cache.Persist("xd3"), false)
+	assertExpiryTracked(t, cache)
+
+	// The persisted key survives past its old TTL, including through garbage collection.
+	clock.Advance(time.Second)
+	cache.tick()
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.LenApprox(), 2)
+}
+
+func TestPersistExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Second)
+
+	assert.Equal(t, cache.Persist("xd"), false)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestTouch(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*50)
+
+	clock.Advance(time.Millisecond * 40)
+	assert.Equal(t, cache.Touch("xd"), true)
+
+	// Past the original expiry, but Touch restarted the countdown.
+	clock.Advance(time.Millisecond * 20)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Millisecond * 40)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestTouchInvalid(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Touch("xd"), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Touch("xd"), false)
+
+	cache.Set("xd2", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+	assert.Equal(t, cache.Touch("xd2"), false)
+}
+
+func TestSetIfGreater(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetIfGreater("xd", "v1", 1, 0), true)
+	assert.Equal(t, cache.SetIfGreater("xd", "v3", 3, 0), true)
+	assert.Equal(t, cache.SetIfGreater("xd", "v2", 2, 0), false)
+	assert.Equal(t, cache.SetIfGreater("xd", "v3 again", 3, 0), false)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "v3")
+
+	// Plain sets reset the version to 0.
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.SetIfGreater("xd", "v1", 1, 0), true)
+}
+
+func TestSetIfGreaterExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.SetIfGreater("xd", "v5", 5, time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.SetIfGreater("xd", "v1", 1, 0), true)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "v1")
+	assert.Equal(t, ok, true)
+}
+
+func TestGetOrSet(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	val, existed := cache.GetOrSet("xd", "xd", 0)
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, existed, false)
+
+	val, existed = cache.GetOrSet("xd", "xd2", 0)
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, existed, true)
+}
+
+func TestGetOrSetExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.GetOrSet("xd", "xd", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, existed := cache.GetOrSet("xd", "xd2", 0)
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, existed, false)
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	var setCount int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, existed := cache.GetOrSet("xd", i, time.Second); !existed {
+				atomic.AddInt32(&setCount, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, setCount, int32(1))
+}
+
+func TestGetOrSetFunc(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	calls := 0
+	build := func(value string) func() interface{} {
+		return func() interface{} {
+			calls++
+			return value
+		}
+	}
+
+	assert.Equal(t, cache.GetOrSetFunc("xd", time.Millisecond*10, build("xd")), "xd")
+	assert.Equal(t, cache.GetOrSetFunc("xd", 0, build("xd2")), "xd")
+	assert.Equal(t, calls, 1)
+
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.GetOrSetFunc("xd", 0, build("xd3")), "xd3")
+	assert.Equal(t, calls, 2)
+	assertExpiryTracked(t, cache)
+}
+
+func TestGetOrSetFuncConcurrent(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	var calls int32
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			val := cache.GetOrSetFunc("xd", time.Second, func() interface{} {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond)
+				return "xd"
+			})
+			assert.Equal(t, val, "xd")
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, calls, int32(1))
+}
+
+func TestSwap(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	old, existed := cache.Swap("xd", "xd", 0)
+	assert.Equal(t, old, nil)
+	assert.Equal(t, existed, false)
+
+	old, existed = cache.Swap("xd", "xd2", time.Second)
+	assert.Equal(t, old, "xd")
+	assert.Equal(t, existed, true)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+
+	ttl, _ := cache.TTL("xd")
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+}
+
+func TestSwapExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	old, existed := cache.Swap("xd", "xd2", 0)
+	assert.Equal(t, old, nil)
+	assert.Equal(t, existed, false)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, ok, true)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	assert.Equal(t, cache.CompareAndSwap("xd", "xd", "xd2", 0), true)
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+
+	assert.Equal(t, cache.CompareAndSwap("xd", "xd", "xd3", 0), false)
+	val, _ = cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+}
+
+func TestUpdate(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	increment := func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		if !exists {
+			return 1, 0, true
+		}
+		return old.(int) + 1, 0, true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Update("counter", increment)
+		}()
+	}
+	wg.Wait()
+
+	val, _ := cache.Get("counter")
+	assert.Equal(t, val, 100)
+}
+
+func TestUpdateNestedMap(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	original := map[string]map[string]int{"scores": {"xd": 1}}
+	cache.Set("user", original, 0)
+
+	cache.Update("user", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		assert.Equal(t, exists, true)
+
+		user := old.(map[string]map[string]int)
+		scores := make(map[string]int, len(user["scores"])+1)
+		for k, v := range user["scores"] {
+			scores[k] = v
+		}
+		scores["xd"]++
+		scores["xd2"] = 5
+
+		updated := make(map[string]map[string]int, len(user))
+		for k, v := range user {
+			updated[k] = v
+		}
+		updated["scores"] = scores
+		return updated, time.Second, true
+	})
+
+	val, _ := cache.Get("user")
+	assert.Equal(t, val, map[string]map[string]int{"scores": {"xd": 2, "xd2": 5}})
+	assert.Equal(t, original, map[string]map[string]int{"scores": {"xd": 1}})
+
+	ttl, _ := cache.TTL("user")
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+	assertExpiryTracked(t, cache)
+}
+
+func TestUpdateDelete(t *testing.T) {
+	rec := &evictionRecorder{}
+	cache := New(WithOnEvict(rec.onEvict))
+	defer cache.Stop()
+
+	cache.Set("remaining", 2, 0)
+
+	decrement := func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		if !exists || old.(int) <= 1 {
+			return nil, 0, false
+		}
+		return old.(int) - 1, 0, true
+	}
+
+	cache.Update("remaining", decrement)
+	val, _ := cache.Get("remaining")
+	assert.Equal(t, val, 1)
+
+	cache.Update("remaining", decrement)
+	assert.Equal(t, cache.Has("remaining"), false)
+	assert.Equal(t, rec.get(), []eviction{
+		{"remaining", 2, ReasonReplaced},
+		{"remaining", 1, ReasonDeleted},
+	})
+
+	// Deleting a missing key does nothing.
+	cache.Update("remaining", decrement)
+	assert.Equal(t, len(rec.get()), 2)
+}
+
+func TestUpdateExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	cache.Update("xd", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		assert.Equal(t, old, nil)
+		assert.Equal(t, exists, false)
+		return "xd2", 0, true
+	})
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assertExpiryTracked(t, cache)
+}
+
+func TestCompareAndSwapMissing(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.CompareAndSwap("xd", nil, "xd", 0), false)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.CompareAndSwap("xd", "xd", "xd2", 0), false)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestCompareAndSwapNonComparable(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", []string{"a", "b"}, 0)
+
+	assert.Equal(t, cache.CompareAndSwap("xd", []string{"a"}, []string{"c"}, 0), false)
+	assert.Equal(t, cache.CompareAndSwap("xd", []string{"a", "b"}, []string{"c"}, 0), true)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, []string{"c"})
+}
+
+func TestValuesEqual(t *testing.T) {
+	a, b := 1, 1
+
+	assert.Equal(t, valuesEqual(nil, nil), true)
+	assert.Equal(t, valuesEqual(nil, 1), false)
+	assert.Equal(t, valuesEqual(1, 1), true)
+	assert.Equal(t, valuesEqual(1, int64(1)), false)
+	assert.Equal(t, valuesEqual(&a, &a), true)
+	assert.Equal(t, valuesEqual(&a, &b), false)
+	assert.Equal(t, valuesEqual(map[string]int{"a": 1}, map[string]int{"a": 1}), true)
+}
+func TestReplace(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Replace("xd", "xd", 0), false)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Replace("xd", "xd2", 0), true)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, ok, true)
+}
+
+func TestReplaceExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Replace("xd", "xd2", 0), false)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestGetAndDelete(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	val, ok := cache.GetAndDelete("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.Stats().Evictions, uint64(1))
+
+	val, ok = cache.GetAndDelete("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestGetAndDeleteExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, ok := cache.GetAndDelete("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, cache.Stats().Evictions, uint64(1))
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	assert.Equal(t, cache.CompareAndDelete("xd", "xd2"), false)
+	assert.Equal(t, cache.Has("xd"), true)
+	assert.Equal(t, len(recorder.get()), 0)
+
+	assert.Equal(t, cache.CompareAndDelete("xd", "xd"), true)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonDeleted}})
+}
+
+func TestCompareAndDeleteMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.CompareAndDelete("xd", nil), false)
+
+	cache.SetMissing("xd", 0)
+	assert.Equal(t, cache.CompareAndDelete("xd", nil), false)
+	assert.Equal(t, cache.LenApprox(), 1)
+}
+
+func TestRename(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Millisecond * 400)
+
+	assert.Equal(t, cache.Rename("xd", "xd2"), true)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	val, ok := cache.Get("xd2")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	ttl, _ := cache.TTL("xd2")
+	assert.Equal(t, ttl, time.Millisecond*600)
+	assert.Equal(t, cache.LenApprox(), 1)
+	assertExpiryTracked(t, cache)
+
+	clock.Advance(time.Millisecond * 600)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func TestRenameOverwrite(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", time.Second)
+
+	assert.Equal(t, cache.Rename("xd", "xd2"), true)
+
+	val, _ := cache.Get("xd2")
+	assert.Equal(t, val, "xd")
+
+	ttl, _ := cache.TTL("xd2")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, cache.LenApprox(), 1)
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd2", value: "xd2", reason: ReasonReplaced}})
+	assertExpiryTracked(t, cache)
+}
+
+func TestRenameMissing(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Rename("xd", "xd2"), false)
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Rename("xd", "xd2"), false)
+	assert.Equal(t, cache.Has("xd2"), false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Rename("xd", "xd"), true)
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestRenameConcurrent(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("a", "xd", time.Second)
+
+	// Renaming back and forth between keys in different shards mustn't deadlock or lose the value.
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				cache.Rename("a", "b")
+				cache.Rename("b", "a")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, cache.Len(), 1)
+	assert.Equal(t, cache.Has("a") || cache.Has("b"), true)
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetMulti(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	entries := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		entries[strconv.Itoa(i)] = i
+	}
+
+	cache.SetMulti(entries, 0)
+
+	assert.Equal(t, cache.Len(), 100)
+	for key, expected := range entries {
+		val, ok := cache.Get(key)
+		assert.Equal(t, val, expected)
+		assert.Equal(t, ok, true)
+	}
+	assert.Equal(t, expiringKeyCount(cache), 0)
+}
+
+func TestSetMultiExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.SetMulti(map[string]interface{}{"xd": "xd", "xd2": "xd2"}, time.Millisecond*10)
+	assert.Equal(t, cache.Has("xd"), true)
+	assert.Equal(t, cache.Has("xd2"), true)
+	assert.Equal(t, expiringKeyCount(cache), 2)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func TestSetMultiWithTTLs(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.SetMultiWithTTLs(map[string]ValueTTL{
+		"xd":  {Value: "xd", Expiration: time.Second},
+		"xd2": {Value: "xd2", Expiration: time.Second * 2},
+		"xd3": {Value: "xd3", Expiration: 0},
+	})
+	assert.Equal(t, cache.GetMulti([]string{"xd", "xd2", "xd3"}), map[string]interface{}{
+		"xd":  "xd",
+		"xd2": "xd2",
+		"xd3": "xd3",
+	})
+	assert.Equal(t, expiringKeyCount(cache), 2)
+
+	// Each key expires on its own schedule.
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Has("xd2"), true)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.Has("xd2"), false)
+	assert.Equal(t, cache.Has("xd3"), true)
+
+	ttl, _ := cache.TTL("xd3")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetManyWithPolicy(t *testing.T) {
+	clock := newFakeClock()
+	entries := map[string]interface{}{"xd": "new", "xd2": "new", "xd3": "new"}
+
+	for _, test := range []struct {
+		policy   OverwritePolicy
+		written  map[string]bool
+		expected map[string]interface{}
+	}{
+		{
+			policy:   OverwriteAlways,
+			written:  map[string]bool{"xd": true, "xd2": true, "xd3": true},
+			expected: map[string]interface{}{"xd": "new", "xd2": "new", "xd3": "new"},
+		},
+		{
+			policy:   OverwriteNever,
+			written:  map[string]bool{"xd": false, "xd2": true, "xd3": true},
+			expected: map[string]interface{}{"xd": "old", "xd2": "new", "xd3": "new"},
+		},
+		{
+			policy:   OverwriteOnlyIfExists,
+			written:  map[string]bool{"xd": true, "xd2": false, "xd3": false},
+			expected: map[string]interface{}{"xd": "new"},
+		},
+	} {
+		cache := New(WithClock(clock))
+
+		// xd exists, xd2 has expired, and xd3 has never been set.
+		cache.Set("xd", "old", 0)
+		cache.Set("xd2", "old", time.Second)
+		clock.Advance(time.Second)
+
+		assert.Equal(t, cache.SetManyWithPolicy(entries, time.Minute, test.policy), test.written, test.policy.String())
+		assert.Equal(t, cache.GetMulti([]string{"xd", "xd2", "xd3"}), test.expected, test.policy.String())
+		assertExpiryTracked(t, cache)
+
+		cache.Stop()
+	}
+}
+
+func TestOverwritePolicyString(t *testing.T) {
+	assert.Equal(t, OverwriteAlways.String(), "always")
+	assert.Equal(t, OverwriteNever.String(), "never")
+	assert.Equal(t, OverwriteOnlyIfExists.String(), "only if exists")
+	assert.Equal(t, OverwritePolicy(100).String(), "unknown")
+}
+
+func TestSetManyWithPolicyTooLarge(t *testing.T) {
+	cache := New(WithMaxValueBytes(2))
+	defer cache.Stop()
+
+	written := cache.SetManyWithPolicy(map[string]interface{}{"xd": "xd", "xd2": "xdxd"}, 0, OverwriteAlways)
+	assert.Equal(t, written, map[string]bool{"xd": true, "xd2": false})
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func benchmarkEntries(n int) map[string]interface{} {
+	entries := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		entries[strconv.Itoa(i)] = i
+	}
+	return entries
+}
+
+// Both set benchmarks run in parallel, so the cost of contending on the store locks is included.
+func BenchmarkSetMulti(b *testing.B) {
+	cache := New()
+	defer cache.Stop()
+	entries := benchmarkEntries(500)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.SetMulti(entries, 0)
+			cache.Get("0")
+		}
+	})
+}
+
+func BenchmarkSetLoop(b *testing.B) {
+	cache := New()
+	defer cache.Stop()
+	entries := benchmarkEntries(500)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for key, val := range entries {
+				cache.Set(key, val, 0)
+			}
+			cache.Get("0")
+		}
+	})
+}
+
+func TestGetMulti(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", time.Second)
+	cache.Set("xd3", "xd3", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	results := cache.GetMulti([]string{"xd", "xd2", "xd3", "xd4"})
+	assert.Equal(t, results, map[string]interface{}{
+		"xd":  "xd",
+		"xd2": "xd2",
+	})
+
+	// The expired key is evicted by the lookup.
+	assert.Equal(t, cache.LenApprox(), 2)
+
+	assert.Equal(t, cache.GetMulti(nil), map[string]interface{}{})
+}
+
+func TestRange(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", 0)
+	cache.Set("xd3", "xd3", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	seen := make(map[string]interface{})
+	cache.Range(func(key string, value interface{}) bool {
+		seen[key] = value
+		return true
+	})
+
+	assert.Equal(t, seen, map[string]interface{}{
+		"xd":  "xd",
+		"xd2": "xd2",
+	})
+}
+
+func TestForEachExpiring(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	start := clock.Now()
+	cache.Set("xd", "xd", time.Second)
+	cache.Set("xd2", "xd", time.Minute)
+	cache.Set("xd3", "xd", 0)
+	cache.Set("xd4", "xd", time.Millisecond)
+	cache.SetMissing("xd5", time.Minute)
+	clock.Advance(time.Millisecond)
+
+	expiries := make(map[string]time.Time)
+	cache.ForEachExpiring(func(key string, expiresAt time.Time) {
+		expiries[key] = expiresAt
+	})
+
+	assert.Equal(t, expiries, map[string]time.Time{
+		"xd":  start.Add(time.Second),
+		"xd2": start.Add(time.Minute),
+	})
+}
+
+func TestRangeEarlyStop(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i, 0)
+	}
+
+	count := 0
+	cache.Range(func(key string, value interface{}) bool {
+		count++
+		return count < 3
+	})
+
+	assert.Equal(t, count, 3)
+}
+
+func TestRangeMutate(t *testing.T) {
+	cache := New(WithShards(1))
+	defer cache.Stop()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i, 0)
+	}
+
+	// Mutating the cache from within fn must not deadlock.
+	cache.Range(func(key string, value interface{}) bool {
+		cache.Delete(key)
+		return true
+	})
+
+	assert.Equal(t, cache.Len(), 0)
+}
+
+func TestTickShardCounts(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithShards(1))
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+
+	checked, evicted := cache.tickShard(cache.shards[0], 10)
+	assert.Equal(t, checked, 10)
+	assert.Equal(t, evicted, 0)
+
+	clock.Advance(time.Hour)
+	checked, evicted = cache.tickShard(cache.shards[0], 10)
+	assert.Equal(t, checked, 10)
+	assert.Equal(t, evicted, 10)
+	assert.Equal(t, expiringKeyCount(cache), 90)
+}
+
+func TestTickConcurrentSets(t *testing.T) {
+	cache := New(WithShards(1))
+	defer cache.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			cache.tick()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				cache.Set(strconv.Itoa(g*500+i), i, time.Millisecond*time.Duration(1+i%5))
+			}
+		}(g)
+	}
+	wg.Wait()
+	<-done
+	assertExpiryTracked(t, cache)
+
+	time.Sleep(time.Millisecond * 10)
+
+	// Every expiring key must still be tracked, so ticking until the tracking slice is empty should collect them all.
+	for i := 0; i < 100 && expiringKeyCount(cache) > 0; i++ {
+		cache.tick()
+	}
+	assert.Equal(t, expiringKeyCount(cache), 0)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestTickResetFromOnEvict(t *testing.T) {
+	clock := newFakeClock()
+	var cache *Hotcache
+	reset := false
+	cache = New(WithClock(clock), WithShards(1), WithOnEvict(func(key string, value interface{}, reason EvictReason) {
+		// Setting the key again from OnEvict must leave it tracked, so the ticker still collects it.
+		if reason == ReasonExpired && !reset {
+			reset = true
+			cache.Set(key, "xd2", time.Second)
+		}
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Second)
+	cache.tick()
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assertExpiryTracked(t, cache)
+
+	clock.Advance(time.Second)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestExpiringKeysBounded(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	// Keys read after they expire are removed from expiringKeys straight away rather than waiting for the ticker.
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		cache.Set(key, i, time.Millisecond)
+		clock.Advance(time.Millisecond)
+		cache.Get(key)
+	}
+	assert.Equal(t, expiringKeyCount(cache), 0)
+
+	// Setting the same key again doesn't track it twice.
+	for i := 0; i < 100; i++ {
+		cache.Set("xd", i, time.Second)
+	}
+	assert.Equal(t, expiringKeyCount(cache), 1)
+	assertExpiryTracked(t, cache)
+}
+
+func TestExpiringKeysUntracked(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Second)
+	}
+
+	cache.Delete("0")
+	cache.GetAndDelete("1")
+	cache.Set("2", 2, 0)
+	cache.Expire("3", 0)
+	cache.CompareAndDelete("4", 4)
+	cache.Swap("5", 5, 0)
+	cache.SetMulti(map[string]interface{}{"6": 6}, 0)
+
+	assert.Equal(t, expiringKeyCount(cache), 3)
+	assertExpiryTracked(t, cache)
+
+	cache.Clear()
+	assert.Equal(t, expiringKeyCount(cache), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestDeletePrefix(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("user:1:sessions", "xd", 0)
+	cache.Set("user:1:profile", "xd", 0)
+	cache.Set("user:12:sessions", "xd", 0)
+	cache.Set("user:2:sessions", "xd", 0)
+	cache.Set("org:1", "xd", 0)
+
+	assert.Equal(t, cache.DeletePrefix("user:1:"), 2)
+	assert.ElementsMatch(t, cache.Keys(), []string{"user:12:sessions", "user:2:sessions", "org:1"})
+
+	// Prefixes overlap on the raw string, not on segments.
+	assert.Equal(t, cache.DeletePrefix("user:1"), 1)
+	assert.ElementsMatch(t, cache.Keys(), []string{"user:2:sessions", "org:1"})
+
+	assert.Equal(t, cache.DeletePrefix("session:"), 0)
+	assert.Equal(t, cache.Len(), 2)
+}
+
+func TestKeysMatching(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("session:1:temp", "xd", 0)
+	cache.Set("session:2:temp", "xd", 0)
+	cache.Set("session:3:saved", "xd", 0)
+	cache.Set("session:4:temp", "xd", time.Millisecond*10)
+	cache.Set("user:1", "xd", 0)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.ElementsMatch(t, cache.KeysMatching("session:*:temp"), []string{"session:1:temp", "session:2:temp"})
+	assert.ElementsMatch(t, cache.KeysMatching("session:?:*"), []string{"session:1:temp", "session:2:temp", "session:3:saved"})
+	assert.ElementsMatch(t, cache.KeysMatching("session:[13]:*"), []string{"session:1:temp", "session:3:saved"})
+	assert.ElementsMatch(t, cache.KeysMatching("user:1"), []string{"user:1"})
+
+	// Patterns must match the whole key.
+	assert.Empty(t, cache.KeysMatching("session:"))
+	assert.Empty(t, cache.KeysMatching("org:*"))
+	assert.Empty(t, cache.KeysMatching("session:[1"))
+}
+
+func TestDeleteMatchingGlob(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("session:1:temp", "xd", 0)
+	cache.Set("session:2:temp", "xd2", 0)
+	cache.Set("session:3:saved", "xd", 0)
+	cache.Set("user:1", "xd", 0)
+
+	assert.Equal(t, cache.DeleteMatchingGlob("session:*:temp"), 2)
+	assert.ElementsMatch(t, cache.Keys(), []string{"session:3:saved", "user:1"})
+	assert.ElementsMatch(t, recorder.get(), []eviction{
+		{key: "session:1:temp", value: "xd", reason: ReasonDeleted},
+		{key: "session:2:temp", value: "xd2", reason: ReasonDeleted},
+	})
+
+	assert.Equal(t, cache.DeleteMatchingGlob("org:*"), 0)
+	assert.Equal(t, cache.DeleteMatchingGlob("session:[3"), 0)
+	assert.Equal(t, cache.Len(), 2)
+}
+
+func TestDeletePrefixOnEvict(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("user:1", "xd", 0)
+	cache.Set("user:2", "xd2", 0)
+	cache.Set("org:1", "xd", 0)
+
+	assert.Equal(t, cache.DeletePrefix("user:"), 2)
+	assert.ElementsMatch(t, recorder.get(), []eviction{
+		{key: "user:1", value: "xd", reason: ReasonDeleted},
+		{key: "user:2", value: "xd2", reason: ReasonDeleted},
+	})
+}
+
+func TestDeleteMatching(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", 2, 0)
+	cache.Set("xd3", 3, 0)
+	cache.Set("expired", 4, time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	// Delete by value type.
+	deleted := cache.DeleteMatching(func(key string, value interface{}) bool {
+		_, ok := value.(int)
+		return ok
+	})
+	assert.Equal(t, deleted, 2)
+	assert.Equal(t, cache.Keys(), []string{"xd"})
+}
+
+func TestDeleteMatchingKeyPattern(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("user:1:temp", "xd", 0)
+	cache.Set("user:2:temp", "xd", 0)
+	cache.Set("user:3", "xd", 0)
+
+	deleted := cache.DeleteMatching(func(key string, value interface{}) bool {
+		return strings.HasSuffix(key, ":temp")
+	})
+	assert.Equal(t, deleted, 2)
+	assert.Equal(t, cache.Keys(), []string{"user:3"})
+}
+
+func TestDeleteMatchingChanged(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	// Keys changed after fn has seen them are left alone, and using the cache from fn doesn't deadlock.
+	deleted := cache.DeleteMatching(func(key string, value interface{}) bool {
+		cache.Set(key, "xd2", 0)
+		return true
+	})
+	assert.Equal(t, deleted, 0)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, ok, true)
+}
+
+func TestGetDetailed(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	val, ok, expired := cache.GetDetailed("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, expired, false)
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	val, ok, expired = cache.GetDetailed("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, expired, false)
+
+	clock.Advance(time.Millisecond * 10)
+
+	val, ok, expired = cache.GetDetailed("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, expired, true)
+
+	// The lookup evicted the key, so it's now a plain miss.
+	_, ok, expired = cache.GetDetailed("xd")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, expired, false)
+}
@@ -0,0 +1,21 @@
+package hotcachetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	assert.Equal(t, clock.Now(), start)
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, clock.Now(), start.Add(time.Minute))
+
+	clock.Set(start)
+	assert.Equal(t, clock.Now(), start)
+}
@@ -0,0 +1,131 @@
+package hotcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lfu tracks how often keys are used, so the least frequently used key can be evicted once the cache is full. Keys are
+// grouped into buckets by their use count, with the buckets kept in ascending order and the keys within each bucket
+// ordered by last use, so every operation is O(1): using a key moves it to the front of the next bucket up, and the
+// victim is at the back of the first bucket.
+//
+// A new key has the lowest possible count, so it would always be the victim of the eviction it triggers. The newest
+// key is skipped over instead, as if the victim was evicted before the new key was added.
+type lfu struct {
+	mutex    sync.Mutex
+	buckets  *list.List
+	elements map[string]*lfuElement
+	newest   string
+}
+
+// lfuBucket holds every key that's been used count times, most recently used first.
+type lfuBucket struct {
+	count int
+	keys  *list.List
+}
+
+// lfuElement locates a key within its bucket.
+type lfuElement struct {
+	bucket *list.Element
+	key    *list.Element
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		buckets:  list.New(),
+		elements: make(map[string]*lfuElement),
+	}
+}
+
+// add counts a use of a key, tracking it if it's new.
+func (l *lfu) add(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.increment(el)
+		return
+	}
+
+	front := l.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).count != 1 {
+		front = l.buckets.PushFront(&lfuBucket{count: 1, keys: list.New()})
+	}
+	l.elements[key] = &lfuElement{
+		bucket: front,
+		key:    front.Value.(*lfuBucket).keys.PushFront(key),
+	}
+	l.newest = key
+}
+
+// access counts a use of an already tracked key.
+func (l *lfu) access(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.increment(el)
+	}
+}
+
+// remove stops tracking a key.
+func (l *lfu) remove(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.unlink(el)
+		delete(l.elements, key)
+	}
+	if l.newest == key {
+		l.newest = ""
+	}
+}
+
+// victim returns the least recently used of the least frequently used keys, other than the newest key unless it's the
+// only one.
+func (l *lfu) victim() (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	front := l.buckets.Front()
+	if front == nil {
+		return "", false
+	}
+
+	candidate := front.Value.(*lfuBucket).keys.Back()
+	if candidate.Value.(string) == l.newest {
+		if prev := candidate.Prev(); prev != nil {
+			candidate = prev
+		} else if next := front.Next(); next != nil {
+			candidate = next.Value.(*lfuBucket).keys.Back()
+		}
+	}
+	return candidate.Value.(string), true
+}
+
+// increment moves a key to the front of the bucket above its current one, creating it if needed. Assumes the mutex is
+// held.
+func (l *lfu) increment(el *lfuElement) {
+	bucket := el.bucket.Value.(*lfuBucket)
+
+	next := el.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).count != bucket.count+1 {
+		next = l.buckets.InsertAfter(&lfuBucket{count: bucket.count + 1, keys: list.New()}, el.bucket)
+	}
+
+	key := el.key.Value
+	l.unlink(el)
+	el.bucket = next
+	el.key = next.Value.(*lfuBucket).keys.PushFront(key)
+}
+
+// unlink removes a key from its bucket, dropping the bucket once it's empty. Assumes the mutex is held.
+func (l *lfu) unlink(el *lfuElement) {
+	bucket := el.bucket.Value.(*lfuBucket)
+	bucket.keys.Remove(el.key)
+	if bucket.keys.Len() == 0 {
+		l.buckets.Remove(el.bucket)
+	}
+}
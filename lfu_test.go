@@ -0,0 +1,144 @@
+package hotcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLFUEvictsColdKeys(t *testing.T) {
+	cache := New(WithMaxKeys(3), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+
+	cache.Set("hot", "xd", 0)
+	cache.Set("warm", "xd", 0)
+	cache.Set("cold", "xd", 0)
+
+	for i := 0; i < 5; i++ {
+		cache.Get("hot")
+	}
+	cache.Get("warm")
+
+	// cold is the least frequently used, even though hot and warm were set before it.
+	cache.Set("new", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"hot", "warm", "new"})
+
+	// new has only been used once, so it goes next, then warm.
+	cache.Set("new2", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"hot", "warm", "new2"})
+
+	cache.Get("new2")
+	cache.Get("new2")
+	cache.Set("new3", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"hot", "new2", "new3"})
+}
+
+func TestLFUTiesEvictLeastRecent(t *testing.T) {
+	cache := New(WithMaxKeys(3), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Set("xd3", "xd", 0)
+
+	cache.Get("xd")
+	cache.Get("xd2")
+	cache.Get("xd3")
+	cache.Get("xd")
+
+	// xd2 and xd3 have been used as often as each other, but xd2 was used less recently.
+	cache.Set("xd4", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd3", "xd4"})
+}
+
+func TestLFUMaxCost(t *testing.T) {
+	cache := New(WithMaxCost(100), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+
+	cache.SetWithCost("hot", "xd", 40, 0)
+	cache.SetWithCost("cold", "xd", 40, 0)
+	cache.Get("hot")
+
+	cache.SetWithCost("xd", "xd", 40, 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"hot", "xd"})
+}
+
+func TestLFUDelete(t *testing.T) {
+	cache := New(WithMaxKeys(2), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Get("xd2")
+	cache.Delete("xd")
+	cache.Set("xd3", "xd", 0)
+
+	lfu := cache.tracker.(*lfu)
+	assert.Equal(t, len(lfu.elements), 2)
+	assert.Equal(t, lfu.buckets.Len(), 2)
+
+	cache.Clear()
+	assert.Equal(t, len(lfu.elements), 0)
+	assert.Equal(t, lfu.buckets.Len(), 0)
+}
+
+func TestLFUBuckets(t *testing.T) {
+	l := newLFU()
+
+	_, ok := l.victim()
+	assert.Equal(t, ok, false)
+
+	l.add("xd")
+	l.add("xd2")
+	l.add("xd3")
+	l.access("xd")
+	l.access("xd")
+	l.access("missing")
+
+	key, _ := l.victim()
+	assert.Equal(t, key, "xd2")
+
+	// Buckets that empty out are dropped, and a key skipping past them gets a new one.
+	l.access("xd2")
+	assert.Equal(t, l.buckets.Len(), 3)
+	l.access("xd2")
+	assert.Equal(t, l.buckets.Len(), 2)
+	l.access("xd2")
+	assert.Equal(t, l.buckets.Len(), 3)
+
+	// xd3 is the least frequently used, but it's the newest key so the next bucket up is used.
+	key, _ = l.victim()
+	assert.Equal(t, key, "xd")
+
+	l.remove("xd")
+	l.remove("missing")
+	key, _ = l.victim()
+	assert.Equal(t, key, "xd2")
+
+	l.remove("xd2")
+	key, _ = l.victim()
+	assert.Equal(t, key, "xd3")
+
+	l.remove("xd3")
+	_, ok = l.victim()
+	assert.Equal(t, ok, false)
+	assert.Equal(t, l.buckets.Len(), 0)
+}
+
+func TestLFUNewestSurvives(t *testing.T) {
+	cache := New(WithMaxKeys(2), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Get("xd")
+	cache.Get("xd2")
+
+	// The new key has been used less than either, but evicting it straight away would make the set pointless.
+	cache.Set("xd3", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd2", "xd3"})
+
+	// Once another key is set, xd3 is fair game.
+	cache.Set("xd4", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd2", "xd4"})
+}
@@ -0,0 +1,112 @@
+package hotcache
+
+// Lists are stored as a []interface{} with the head at index 0. Like every other value, the slice is never modified
+// once it's stored, so each change stores a new copy, and slices returned by Get must not be modified either.
+
+// LPush adds values to the head of the list stored at key one at a time, so the last value ends up first, and returns
+// the list's new length. Missing keys are created as a new list with the default TTL from WithDefaultTTL, existing
+// lists keep their expiry. If key holds a value that isn't a list nothing is pushed and 0 is returned.
+func (h *Hotcache) LPush(key string, values ...interface{}) int {
+	return h.push(key, values, func(current []interface{}) []interface{} {
+		updated := make([]interface{}, 0, len(values)+len(current))
+		for i := len(values) - 1; i >= 0; i-- {
+			updated = append(updated, values[i])
+		}
+		return append(updated, current...)
+	})
+}
+
+// RPush adds values to the tail of the list stored at key in order and returns the list's new length, see LPush.
+func (h *Hotcache) RPush(key string, values ...interface{}) int {
+	return h.push(key, values, func(current []interface{}) []interface{} {
+		updated := make([]interface{}, 0, len(current)+len(values))
+		updated = append(updated, current...)
+		return append(updated, values...)
+	})
+}
+
+// LPop removes and returns the value at the head of the list stored at key. It returns false if the key is missing,
+// expired, or isn't a list. The key is deleted once its list is empty.
+func (h *Hotcache) LPop(key string) (interface{}, bool) {
+	return h.pop(key, func(current []interface{}) (interface{}, []interface{}) {
+		return current[0], current[1:]
+	})
+}
+
+// RPop removes and returns the value at the tail of the list stored at key, see LPop.
+func (h *Hotcache) RPop(key string) (interface{}, bool) {
+	return h.pop(key, func(current []interface{}) (interface{}, []interface{}) {
+		last := len(current) - 1
+		return current[last], current[:last]
+	})
+}
+
+// LLen returns the length of the list stored at key, or 0 if key is missing, expired, or isn't a list.
+func (h *Hotcache) LLen(key string) int {
+	val, ok := h.Get(key)
+	if !ok {
+		return 0
+	}
+
+	list, _ := val.([]interface{})
+	return len(list)
+}
+
+// push stores the list returned by fn, which is passed the list currently stored at key.
+func (h *Hotcache) push(key string, values []interface{}, fn func(current []interface{}) []interface{}) int {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	exists = exists && old.live(h.now())
+
+	var current []interface{}
+	if exists {
+		list, ok := old.value.([]interface{})
+		if !ok {
+			return 0
+		}
+		current = list
+	}
+
+	if len(values) == 0 {
+		return len(current)
+	}
+
+	updated := fn(current)
+	if exists {
+		h.replaceValue(s, key, old, updated)
+	} else {
+		h.set(s, key, updated, h.options.defaultTTL)
+	}
+	return len(updated)
+}
+
+// pop removes a value from the list stored at key, fn is passed the list and returns the value to pop and what's left.
+func (h *Hotcache) pop(key string, fn func(current []interface{}) (interface{}, []interface{})) (interface{}, bool) {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	if !exists || !old.live(h.now()) {
+		return nil, false
+	}
+
+	current, ok := old.value.([]interface{})
+	if !ok || len(current) == 0 {
+		return nil, false
+	}
+
+	value, rest := fn(current)
+	if len(rest) == 0 {
+		h.remove(s, key, old, ReasonDeleted)
+	} else {
+		// rest shares its backing array with the stored list, which is fine as neither is ever modified.
+		h.replaceValue(s, key, old, rest)
+	}
+	return value, true
+}
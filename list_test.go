@@ -0,0 +1,124 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLPush(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.LPush("xd", "a", "b"), 2)
+	assert.Equal(t, cache.LPush("xd", "c"), 3)
+	assert.Equal(t, cache.LPush("xd"), 3)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, []interface{}{"c", "b", "a"})
+	assert.Equal(t, cache.LLen("xd"), 3)
+
+	// Pushing nothing doesn't create the key.
+	assert.Equal(t, cache.LPush("xd2"), 0)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func TestRPush(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.RPush("xd", "a", "b"), 2)
+	assert.Equal(t, cache.RPush("xd", "c"), 3)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, []interface{}{"a", "b", "c"})
+}
+
+func TestPushWrongType(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	assert.Equal(t, cache.LPush("xd", "a"), 0)
+	assert.Equal(t, cache.RPush("xd", "a"), 0)
+	assert.Equal(t, cache.LLen("xd"), 0)
+
+	val, ok := cache.LPop("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+
+	val, _ = cache.Get("xd")
+	assert.Equal(t, val, "xd")
+}
+
+func TestPop(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.RPush("xd", "a", "b", "c")
+
+	val, ok := cache.LPop("xd")
+	assert.Equal(t, val, "a")
+	assert.Equal(t, ok, true)
+
+	val, ok = cache.RPop("xd")
+	assert.Equal(t, val, "c")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.LLen("xd"), 1)
+
+	// Popping the last value deletes the key.
+	val, ok = cache.RPop("xd")
+	assert.Equal(t, val, "b")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	val, ok = cache.LPop("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+}
+
+func TestQueue(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	for i := 0; i < 5; i++ {
+		cache.RPush("xd", i)
+	}
+
+	// Pushing to the tail and popping from the head is first in, first out, and popped values aren't overwritten.
+	first, _ := cache.Get("xd")
+	for i := 0; i < 5; i++ {
+		val, _ := cache.LPop("xd")
+		assert.Equal(t, val, i)
+		cache.RPush("xd", i+5)
+	}
+	assert.Equal(t, first, []interface{}{0, 1, 2, 3, 4})
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, []interface{}{5, 6, 7, 8, 9})
+}
+
+func TestListExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithDefaultTTL(time.Second))
+	defer cache.Stop()
+
+	cache.RPush("xd", "a")
+	clock.Advance(time.Millisecond * 600)
+
+	// Pushing to an existing list keeps its expiry.
+	cache.RPush("xd", "b")
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*400)
+
+	clock.Advance(time.Millisecond * 400)
+	assert.Equal(t, cache.LLen("xd"), 0)
+
+	_, ok := cache.RPop("xd")
+	assert.Equal(t, ok, false)
+
+	// An expired list is replaced with a new one.
+	assert.Equal(t, cache.RPush("xd", "c"), 1)
+}
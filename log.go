@@ -0,0 +1,18 @@
+package hotcache
+
+import (
+	"context"
+	"log/slog"
+)
+
+// debugEnabled checks whether there's a logger from WithLogger that writes debug logs, so callers can skip building
+// them entirely otherwise.
+func (h *Hotcache) debugEnabled() bool {
+	return h.options.logger != nil && h.options.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// debug writes a debug log, callers should check debugEnabled first. It must be called without holding any store
+// mutex, as handlers may be slow.
+func (h *Hotcache) debug(msg string, attrs ...slog.Attr) {
+	h.options.logger.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
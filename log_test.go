@@ -0,0 +1,130 @@
+package hotcache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureHandler records every log written to it, with its attributes flattened into a map.
+type captureHandler struct {
+	mutex sync.Mutex
+	level slog.Level
+	logs  []capturedLog
+}
+
+type capturedLog struct {
+	msg   string
+	attrs map[string]interface{}
+}
+
+func (c *captureHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= c.level
+}
+
+func (c *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	log := capturedLog{msg: r.Message, attrs: make(map[string]interface{})}
+	r.Attrs(func(attr slog.Attr) bool {
+		log.attrs[attr.Key] = attr.Value.Any()
+		return true
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.logs = append(c.logs, log)
+	return nil
+}
+
+func (c *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return c }
+func (c *captureHandler) WithGroup(string) slog.Handler      { return c }
+
+// find returns every captured log with the given message.
+func (c *captureHandler) find(msg string) []capturedLog {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var logs []capturedLog
+	for _, log := range c.logs {
+		if log.msg == msg {
+			logs = append(logs, log)
+		}
+	}
+	return logs
+}
+
+func TestWithLoggerTick(t *testing.T) {
+	clock := newFakeClock()
+	handler := &captureHandler{level: slog.LevelDebug}
+	cache := New(WithClock(clock), WithStartPaused(true), WithLogger(slog.New(handler)))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Set("xd2", "xd", time.Minute)
+	clock.Advance(time.Second)
+	cache.tick()
+
+	assert.Equal(t, handler.find("hotcache: gc tick"), []capturedLog{{
+		msg: "hotcache: gc tick",
+		attrs: map[string]interface{}{
+			"rounds":  int64(1),
+			"checked": int64(2),
+			"evicted": int64(1),
+		},
+	}})
+}
+
+func TestWithLoggerOverwrite(t *testing.T) {
+	handler := &captureHandler{level: slog.LevelDebug}
+	cache := New(WithLogger(slog.New(handler)))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	assert.Len(t, handler.find("hotcache: key overwritten"), 0)
+
+	cache.Set("xd", "xd2", 0)
+	assert.Equal(t, handler.find("hotcache: key overwritten"), []capturedLog{{
+		msg:   "hotcache: key overwritten",
+		attrs: map[string]interface{}{"key": "xd"},
+	}})
+}
+
+func TestWithLoggerLoaderErrors(t *testing.T) {
+	handler := &captureHandler{level: slog.LevelDebug}
+	cache := New(WithLogger(slog.New(handler)))
+	defer cache.Stop()
+
+	loadErr := errors.New("backend down")
+	cache.GetOrCompute("xd", 0, func() (interface{}, error) {
+		return nil, loadErr
+	})
+	cache.GetOrComputeMulti([]string{"xd", "xd2"}, 0, func([]string) (map[string]interface{}, error) {
+		return nil, loadErr
+	})
+
+	assert.Equal(t, handler.find("hotcache: compute failed"), []capturedLog{{
+		msg:   "hotcache: compute failed",
+		attrs: map[string]interface{}{"key": "xd", "error": loadErr},
+	}})
+	assert.Equal(t, handler.find("hotcache: batch load failed"), []capturedLog{{
+		msg:   "hotcache: batch load failed",
+		attrs: map[string]interface{}{"keys": int64(2), "error": loadErr},
+	}})
+}
+
+func TestWithLoggerLevel(t *testing.T) {
+	handler := &captureHandler{level: slog.LevelInfo}
+	cache := New(WithStartPaused(true), WithLogger(slog.New(handler)))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd", "xd2", 0)
+	cache.tick()
+
+	// Nothing is logged above debug level.
+	assert.Len(t, handler.logs, 0)
+}
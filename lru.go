@@ -0,0 +1,66 @@
+package hotcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru tracks the order keys were last used in, so the least recently used key can be evicted once the cache is full.
+// It has its own mutex as Get only holds a read lock on the store while marking a key as used.
+type lru struct {
+	mutex    sync.Mutex
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// add marks a key as the most recently used, tracking it if it's new.
+func (l *lru) add(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.order.MoveToFront(el)
+		return
+	}
+	l.elements[key] = l.order.PushFront(key)
+}
+
+// access marks an already tracked key as the most recently used.
+func (l *lru) access(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.order.MoveToFront(el)
+	}
+}
+
+// remove stops tracking a key.
+func (l *lru) remove(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if el, ok := l.elements[key]; ok {
+		l.order.Remove(el)
+		delete(l.elements, key)
+	}
+}
+
+// victim returns the least recently used key.
+func (l *lru) victim() (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	el := l.order.Back()
+	if el == nil {
+		return "", false
+	}
+	return el.Value.(string), true
+}
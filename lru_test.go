@@ -0,0 +1,211 @@
+package hotcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxKeys(t *testing.T) {
+	cache := New(WithMaxKeys(2))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Set("xd3", "xd", 0)
+
+	assert.Equal(t, cache.LenApprox(), 2)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Has("xd2"), true)
+	assert.Equal(t, cache.Has("xd3"), true)
+}
+
+func TestMaxKeysRecentlyUsed(t *testing.T) {
+	cache := New(WithMaxKeys(3))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Set("xd3", "xd", 0)
+
+	// Using xd makes xd2 the least recently used key.
+	cache.Get("xd")
+	cache.Set("xd4", "xd", 0)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd3", "xd4"})
+
+	// Has counts as a use too.
+	cache.Has("xd3")
+	cache.Set("xd5", "xd", 0)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd3", "xd4", "xd5"})
+}
+
+func TestMaxKeysPeek(t *testing.T) {
+	cache := New(WithMaxKeys(2))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+
+	// Unlike Get, peeking at xd leaves it as the least recently used key.
+	val, ok := cache.Peek("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	cache.Set("xd3", "xd", 0)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd2", "xd3"})
+}
+
+func TestMaxKeysOverwrite(t *testing.T) {
+	cache := New(WithMaxKeys(2))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+
+	// Overwriting a key doesn't grow the cache, but does mark it as used.
+	cache.Set("xd", "xd2", 0)
+	assert.Equal(t, cache.LenApprox(), 2)
+
+	cache.Set("xd3", "xd", 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd3"})
+}
+
+func TestMaxKeysDelete(t *testing.T) {
+	cache := New(WithMaxKeys(2))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+	cache.Delete("xd")
+	cache.Set("xd3", "xd", 0)
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd2", "xd3"})
+	assert.Equal(t, len(cache.tracker.(*lru).elements), 2)
+
+	cache.Clear()
+	assert.Equal(t, len(cache.tracker.(*lru).elements), 0)
+	assert.Equal(t, cache.tracker.(*lru).order.Len(), 0)
+}
+
+func TestSetMaxKeys(t *testing.T) {
+	recorder := &evictionRecorder{}
+	cache := New(WithMaxKeys(5), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	for i := 0; i < 5; i++ {
+		cache.Set("xd"+strconv.Itoa(i), i, 0)
+	}
+	cache.Get("xd0")
+
+	// Shrinking evicts the least recently used keys.
+	assert.Equal(t, cache.SetMaxKeys(2), 3)
+	assert.Equal(t, cache.LenApprox(), 2)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd0", "xd4"})
+	assert.Equal(t, recorder.get(), []eviction{
+		{key: "xd1", value: 1, reason: ReasonCapacity},
+		{key: "xd2", value: 2, reason: ReasonCapacity},
+		{key: "xd3", value: 3, reason: ReasonCapacity},
+	})
+
+	// New keys are bounded by the new limit.
+	cache.Set("xd5", 5, 0)
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd0", "xd5"})
+
+	// Growing doesn't evict anything.
+	assert.Equal(t, cache.SetMaxKeys(3), 0)
+	cache.Set("xd6", 6, 0)
+	assert.Equal(t, cache.LenApprox(), 3)
+
+	// Nor does removing the bound.
+	assert.Equal(t, cache.SetMaxKeys(0), 0)
+	for i := 7; i < 10; i++ {
+		cache.Set("xd"+strconv.Itoa(i), i, 0)
+	}
+	assert.Equal(t, cache.LenApprox(), 6)
+
+	clone := cache.Clone()
+	defer clone.Stop()
+	assert.Equal(t, clone.LenApprox(), 6)
+}
+
+func TestSetMaxKeysUnbounded(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", 0)
+
+	assert.Equal(t, cache.SetMaxKeys(1), 0)
+	assert.Equal(t, cache.LenApprox(), 2)
+}
+
+func TestSetMaxKeysConcurrent(t *testing.T) {
+	cache := New(WithMaxKeys(100))
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				cache.Set(strconv.Itoa(i)+":"+strconv.Itoa(j), j, 0)
+			}
+		}(i)
+	}
+
+	cache.SetMaxKeys(10)
+	wg.Wait()
+
+	assert.Equal(t, cache.LenApprox(), 10)
+	assert.Equal(t, cache.Len(), 10)
+}
+
+func TestMaxCost(t *testing.T) {
+	cache := New(WithMaxCost(100))
+	defer cache.Stop()
+
+	cache.SetWithCost("xd", "xd", 40, 0)
+	cache.SetWithCost("xd2", "xd", 40, 0)
+	assert.Equal(t, cache.Cost(), int64(80))
+
+	cache.Get("xd")
+	cache.SetWithCost("xd3", "xd", 40, 0)
+
+	// xd2 was the least recently used, evicting it brings the cost back under the bound.
+	assert.Equal(t, cache.Cost(), int64(80))
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd3"})
+
+	cache.SetWithCost("xd4", "xd", 90, 0)
+	assert.Equal(t, cache.Cost(), int64(90))
+	assert.Equal(t, cache.Keys(), []string{"xd4"})
+}
+
+func TestCostTracking(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.SetWithCost("xd", "xd", 10, 0)
+	cache.SetWithCost("xd2", "xd", 20, 0)
+	cache.Set("xd3", "xd", 0)
+	assert.Equal(t, cache.Cost(), int64(30))
+
+	// Overwriting replaces the old cost.
+	cache.SetWithCost("xd", "xd", 5, 0)
+	assert.Equal(t, cache.Cost(), int64(25))
+
+	cache.Delete("xd2")
+	assert.Equal(t, cache.Cost(), int64(5))
+
+	// Keeping the value, but changing its expiry keeps its cost.
+	cache.Expire("xd", time.Second)
+	assert.Equal(t, cache.Cost(), int64(5))
+
+	cache.Clear()
+	assert.Equal(t, cache.Cost(), int64(0))
+}
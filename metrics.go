@@ -0,0 +1,23 @@
+package hotcache
+
+// MetricsCollector receives the cache's metrics as they happen, so they can be exported to a monitoring system such as
+// Prometheus or OpenTelemetry, see WithMetricsCollector. Methods are called on hot paths, often while a lock is held,
+// so they must be fast and safe for concurrent use, and mustn't use the cache.
+type MetricsCollector interface {
+	// IncHit is called for every lookup that finds a key.
+	IncHit()
+	// IncMiss is called for every lookup that doesn't find a key.
+	IncMiss()
+	// IncEviction is called for every key removed from cache, including keys that are overwritten.
+	IncEviction(reason EvictReason)
+	// ObserveSize is called on every tick of the garbage collector with the number of keys in cache.
+	ObserveSize(n int)
+}
+
+// noopCollector is the MetricsCollector used when none is configured.
+type noopCollector struct{}
+
+func (noopCollector) IncHit()                 {}
+func (noopCollector) IncMiss()                {}
+func (noopCollector) IncEviction(EvictReason) {}
+func (noopCollector) ObserveSize(int)         {}
@@ -0,0 +1,150 @@
+package hotcache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCollector records every call made to it.
+type fakeCollector struct {
+	hits   int64
+	misses int64
+	size   int64
+
+	mutex     sync.Mutex
+	evictions map[EvictReason]int
+}
+
+func (c *fakeCollector) IncHit()  { atomic.AddInt64(&c.hits, 1) }
+func (c *fakeCollector) IncMiss() { atomic.AddInt64(&c.misses, 1) }
+
+func (c *fakeCollector) IncEviction(reason EvictReason) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.evictions == nil {
+		c.evictions = make(map[EvictReason]int)
+	}
+	c.evictions[reason]++
+}
+
+func (c *fakeCollector) ObserveSize(n int) { atomic.StoreInt64(&c.size, int64(n)) }
+
+func TestWithMetricsCollector(t *testing.T) {
+	collector := &fakeCollector{}
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithMetricsCollector(collector))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd", "xd2", 0)
+	cache.Set("xd2", "xd", time.Millisecond)
+	cache.Set("xd3", "xd", 0)
+	cache.Get("xd")
+	cache.Has("xd")
+	cache.Get("missing")
+	cache.Delete("xd")
+
+	clock.Advance(time.Millisecond)
+	cache.tick()
+
+	assert.Equal(t, atomic.LoadInt64(&collector.hits), int64(2))
+	assert.Equal(t, atomic.LoadInt64(&collector.misses), int64(1))
+	assert.Equal(t, atomic.LoadInt64(&collector.size), int64(1))
+	assert.Equal(t, collector.evictions, map[EvictReason]int{
+		ReasonReplaced: 1,
+		ReasonDeleted:  1,
+		ReasonExpired:  1,
+	})
+}
+
+func TestWithMetricsCollectorNil(t *testing.T) {
+	cache := New(WithMetricsCollector(nil))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.metrics, MetricsCollector(noopCollector{}))
+	cache.Get("xd")
+}
+
+func BenchmarkGetNoopCollector(b *testing.B) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get("xd")
+	}
+}
+
+// counter is the part of prometheus.Counter used by promCollector, so any prometheus.Counter can be passed to it.
+type counter interface {
+	Inc()
+}
+
+// gauge is the part of prometheus.Gauge used by promCollector.
+type gauge interface {
+	Set(float64)
+}
+
+// promCollector adapts Prometheus counters and gauges into a MetricsCollector. Evictions would typically be a
+// prometheus.CounterVec labelled by reason, with each label's counter passed in up front so IncEviction doesn't
+// allocate.
+type promCollector struct {
+	hits      counter
+	misses    counter
+	evictions map[EvictReason]counter
+	size      gauge
+}
+
+func (c *promCollector) IncHit()  { c.hits.Inc() }
+func (c *promCollector) IncMiss() { c.misses.Inc() }
+
+func (c *promCollector) IncEviction(reason EvictReason) {
+	if counter, ok := c.evictions[reason]; ok {
+		counter.Inc()
+	}
+}
+
+func (c *promCollector) ObserveSize(n int) { c.size.Set(float64(n)) }
+
+// exampleCounter and exampleGauge stand in for the Prometheus types in the example.
+type exampleCounter struct{ value uint64 }
+
+func (c *exampleCounter) Inc() { atomic.AddUint64(&c.value, 1) }
+
+type exampleGauge struct {
+	mutex sync.Mutex
+	value float64
+}
+
+func (g *exampleGauge) Set(v float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value = v
+}
+
+func ExampleWithMetricsCollector() {
+	hits, misses, deleted := &exampleCounter{}, &exampleCounter{}, &exampleCounter{}
+	cache := New(WithMetricsCollector(&promCollector{
+		hits:      hits,
+		misses:    misses,
+		evictions: map[EvictReason]counter{ReasonDeleted: deleted},
+		size:      &exampleGauge{},
+	}))
+	defer cache.Stop()
+
+	cache.Set("key", "value", 0)
+	cache.Get("key")
+	cache.Get("missing")
+	cache.Delete("key")
+
+	fmt.Println(hits.value, misses.value, deleted.value)
+	// Output: 1 1 1
+}
@@ -0,0 +1,76 @@
+package hotcache
+
+import "time"
+
+// State describes what GetWithState found for a key.
+type State int
+
+const (
+	// StateMiss means the key isn't in cache, or has expired.
+	StateMiss State = iota
+	// StateHit means the key is in cache with a value.
+	StateHit
+	// StateNegativeHit means the key was cached as known to be missing with SetMissing.
+	StateNegativeHit
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateMiss:
+		return "miss"
+	case StateHit:
+		return "hit"
+	case StateNegativeHit:
+		return "negative hit"
+	default:
+		return "unknown"
+	}
+}
+
+// SetMissing caches that a key is known to be missing, for example because a slow backend reported it doesn't exist.
+// Use expiration of 0 for no expiry. The key is reported as missing by every other method, only GetWithState can tell
+// it apart from a key that isn't cached at all. Setting a value for the key replaces the tombstone.
+func (h *Hotcache) SetMissing(key string, expiration time.Duration) {
+	val := h.newValue(nil, expiration)
+	val.negative = true
+
+	s := h.shard(key)
+	s.storeMutex.Lock()
+	h.setValue(s, key, val)
+	h.unlockStore(s)
+}
+
+// GetWithState retrieves a key that isn't expired from cache, reporting whether it was found, cached as missing with
+// SetMissing, or not cached at all. The value is only set for StateHit.
+func (h *Hotcache) GetWithState(key string) (interface{}, State) {
+	s := h.shard(key)
+
+	s.storeMutex.RLock()
+	val, ok := s.store[key]
+	expired := ok && val.expired(h.now())
+
+	// Only values count as hits, tombstones aren't marked as used so they don't hold off the eviction policy.
+	hit := ok && !expired && !val.negative
+	if hit {
+		h.access(key, val)
+	}
+	s.storeMutex.RUnlock()
+
+	h.recordLookup(hit)
+
+	if expired {
+		s.storeMutex.Lock()
+		h.evict(s, key)
+		h.unlockStore(s)
+	}
+
+	switch {
+	case !ok || expired:
+		return nil, StateMiss
+	case val.negative:
+		return nil, StateNegativeHit
+	default:
+		return val.value, StateHit
+	}
+}
@@ -0,0 +1,105 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWithState(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	val, state := cache.GetWithState("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, state, StateMiss)
+
+	cache.Set("xd", "xd", 0)
+
+	val, state = cache.GetWithState("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, state, StateHit)
+}
+
+func TestSetMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.SetMissing("xd", 0)
+
+	val, state := cache.GetWithState("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, state, StateNegativeHit)
+
+	// Everything else sees the key as missing.
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Keys(), []string{})
+	assert.Equal(t, cache.Len(), 0)
+
+	// Setting a value replaces the tombstone.
+	assert.Equal(t, cache.SetNX("xd", "xd", 0), true)
+
+	val, state = cache.GetWithState("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, state, StateHit)
+}
+
+func TestGetWithStateNegativeHitStats(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.SetMissing("xd", 0)
+	cache.Set("xd2", "xd2", 0)
+
+	_, state := cache.GetWithState("xd")
+	assert.Equal(t, state, StateNegativeHit)
+	_, state = cache.GetWithState("xd2")
+	assert.Equal(t, state, StateHit)
+
+	stats := cache.Stats()
+	assert.Equal(t, stats.Hits, uint64(1))
+	assert.Equal(t, stats.Misses, uint64(1))
+}
+
+func TestGetWithStateNegativeHitNotUsed(t *testing.T) {
+	cache := New(WithMaxKeys(2))
+	defer cache.Stop()
+
+	cache.SetMissing("xd", 0)
+	cache.Set("xd2", "xd2", 0)
+
+	// Looking up the tombstone doesn't count as using it, so it's still the least recently used key.
+	cache.GetWithState("xd")
+	cache.Set("xd3", "xd3", 0)
+
+	_, state := cache.GetWithState("xd")
+	assert.Equal(t, state, StateMiss)
+	assert.Equal(t, cache.Has("xd2"), true)
+}
+
+func TestSetMissingExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.SetMissing("xd", time.Millisecond*10)
+
+	_, state := cache.GetWithState("xd")
+	assert.Equal(t, state, StateNegativeHit)
+
+	clock.Advance(time.Millisecond * 10)
+
+	_, state = cache.GetWithState("xd")
+	assert.Equal(t, state, StateMiss)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, StateMiss.String(), "miss")
+	assert.Equal(t, StateHit.String(), "hit")
+	assert.Equal(t, StateNegativeHit.String(), "negative hit")
+}
@@ -0,0 +1,428 @@
+package hotcache
+
+import (
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+const defaultTickInterval = time.Millisecond * 100
+
+// defaultGCBatchSize is the number of expiring keys checked per tick, spread across every shard.
+const defaultGCBatchSize = 1000
+
+// adaptiveGCMaxRounds caps how many batches a tick checks with WithAdaptiveGC.
+const adaptiveGCMaxRounds = 16
+
+// Option configures a Hotcache, pass them to New.
+type Option func(*options)
+
+// options holds the configuration of a Hotcache.
+type options struct {
+	tickInterval     time.Duration
+	gcBatchSize      int
+	adaptiveGC       bool
+	wheelResolution  time.Duration
+	shards           int
+	capacityHint     int
+	maxKeys          int
+	maxCost          int64
+	evictionPolicy   EvictionPolicy
+	onEvict          OnEvictFunc
+	defaultTTL       time.Duration
+	expiryJitter     time.Duration
+	readOptimized    bool
+	zeroTTLImmediate bool
+	startPaused      bool
+	maxValueBytes    int64
+	sizeOf           SizeFunc
+	logger           *slog.Logger
+	bloomSize        uint
+	bloomHashes      uint
+	accessTracking   bool
+	staleWindow      time.Duration
+	extendTTLOnWrite bool
+	loaderTimeout    time.Duration
+	writer           Writer
+	writeMode        WriteMode
+	clock            Clock
+	randSource       rand.Source
+	metrics          MetricsCollector
+
+	refreshThreshold time.Duration
+	refreshLoader    func(key string) (interface{}, error)
+
+	statsInterval time.Duration
+	statsReporter func(Stats)
+
+	compactionInterval time.Duration
+}
+
+// defaultOptions returns the configuration New uses when no options are passed.
+func defaultOptions() options {
+	return options{
+		tickInterval: defaultTickInterval,
+		gcBatchSize:  defaultGCBatchSize,
+		shards:       defaultShards,
+		defaultTTL:   NoExpiry,
+		sizeOf:       defaultSizeOf,
+		clock:        realClock{},
+		metrics:      noopCollector{},
+	}
+}
+
+// WithTickInterval sets how often the garbage collector checks for expired keys, defaults to 100ms. Intervals that
+// aren't positive are ignored.
+func WithTickInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.tickInterval = d
+		}
+	}
+}
+
+// WithGCBatchSize sets how many random expiring keys the garbage collector checks each tick, defaults to 1000. Larger
+// batches keep up with caches holding many expiring keys, at the cost of more work per tick. Sizes that aren't
+// positive are ignored.
+func WithGCBatchSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.gcBatchSize = n
+		}
+	}
+}
+
+// WithAdaptiveGC makes the garbage collector check another batch of keys within the same tick whenever more than a
+// quarter of the last batch had expired, up to 16 batches per tick. This keeps up with bursts of keys expiring at
+// once, without checking more keys than usual when few are expiring. Defaults to off.
+func WithAdaptiveGC(enabled bool) Option {
+	return func(o *options) {
+		o.adaptiveGC = enabled
+	}
+}
+
+// WithTimingWheel makes the garbage collector schedule each expiring key into a timing wheel of resolution wide slots,
+// instead of checking random keys. Each tick only checks the keys that became due since the last one, so every expired
+// key is evicted by the first tick at least resolution after it expires, however many keys are in cache, and ticks
+// don't spend time on keys that haven't expired. It costs some memory and a little work per set for each expiring key.
+// WithGCBatchSize and WithAdaptiveGC have no effect with it. A resolution around WithTickInterval's works best.
+// Resolutions that aren't positive are ignored.
+func WithTimingWheel(resolution time.Duration) Option {
+	return func(o *options) {
+		if resolution > 0 {
+			o.wheelResolution = resolution
+		}
+	}
+}
+
+// WithShards sets how many shards the store is split into, defaults to 16. Each shard has its own locks, so more
+// shards means less contention between concurrent operations on different keys. Counts that aren't positive are
+// ignored.
+func WithShards(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.shards = n
+		}
+	}
+}
+
+// WithCapacityHint presizes the store for about n keys, split evenly between shards, so filling it doesn't have to
+// grow it over and over. The list of expiring keys is presized for n of them too. Clear keeps the store presized. It
+// doesn't bound the cache, see WithMaxKeys for that. Hints that aren't positive are ignored.
+func WithCapacityHint(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.capacityHint = n
+		}
+	}
+}
+
+// WithMaxKeys bounds the number of keys the cache holds, once it's full the least recently used key is evicted to make
+// room for new ones, or whichever key WithEvictionPolicy picks. Get and Has count as using a key. The bound can be
+// changed later with SetMaxKeys. Defaults to 0, which is unbounded.
+func WithMaxKeys(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxKeys = n
+		}
+	}
+}
+
+// WithMaxCost bounds the total cost of the keys the cache holds, see SetWithCost. Once the bound is exceeded keys are
+// evicted until it's back within it, least recently used first unless WithEvictionPolicy says otherwise. Defaults to
+// 0, which is unbounded.
+func WithMaxCost(cost int64) Option {
+	return func(o *options) {
+		if cost > 0 {
+			o.maxCost = cost
+		}
+	}
+}
+
+// WithEvictionPolicy sets how a cache bounded by WithMaxKeys or WithMaxCost picks which key to evict, defaults to
+// PolicyLRU. PolicyLFU keeps keys that are used often even if they haven't been used lately, which suits workloads
+// with a stable set of hot keys. Setting a key counts as a use, just like Get and Has. Unknown policies are ignored.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(o *options) {
+		if policy == PolicyLRU || policy == PolicyLFU {
+			o.evictionPolicy = policy
+		}
+	}
+}
+
+// WithOnEvict sets a callback that's called with every key that leaves the cache and the reason it left. It's called
+// after the cache's locks have been released, so it's safe to use the cache from within it.
+func WithOnEvict(fn OnEvictFunc) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithDefaultTTL sets the expiration used by SetDefault and by keys created by SAdd, LPush, and RPush, defaults to no
+// expiry. It doesn't affect the expiration passed to Set. Negative durations are ignored.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(o *options) {
+		if d >= 0 {
+			o.defaultTTL = d
+		}
+	}
+}
+
+// WithExpiryJitter adds a random duration between 0 and maxJitter to the expiry of every key set with a TTL, so keys
+// set together with the same TTL don't all expire at once. TTL reports the jittered time remaining. Defaults to 0,
+// which disables jitter. Durations that aren't positive are ignored.
+func WithExpiryJitter(maxJitter time.Duration) Option {
+	return func(o *options) {
+		if maxJitter > 0 {
+			o.expiryJitter = maxJitter
+		}
+	}
+}
+
+// WithReadOptimized makes lookups lock free, for workloads that read far more often than they write. Each shard keeps a
+// copy of its keys that Get, GetWithExpiry, GetDetailed, Has, and Peek read from without locking, which is replaced
+// on every write. This makes every write copy every key in its shard, so writes get slower as the cache grows.
+// Defaults to off.
+func WithReadOptimized(enabled bool) Option {
+	return func(o *options) {
+		o.readOptimized = enabled
+	}
+}
+
+// WithClock sets the clock used to calculate and check expiries, defaults to the system clock. It's mostly useful for
+// tests, where a fake clock such as hotcachetest.FakeClock can be advanced rather than sleeping. The garbage collecting
+// ticker still runs on real time. A nil clock is ignored.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// WithExtendTTLOnWrite restarts the TTL of a key whenever its value is updated in place, such as by Increment, HSet,
+// HDel, or LPush, rather than keeping its expiry. This keeps accumulators like rate counters alive for as long as
+// they're written to. The TTL the key was last set with is used, and keys without an expiry keep not having one. Set
+// and the other calls that replace a key set its expiry as usual, so a Set with 0 still removes it. Defaults to off.
+func WithExtendTTLOnWrite(enabled bool) Option {
+	return func(o *options) {
+		o.extendTTLOnWrite = enabled
+	}
+}
+
+// WithLoaderTimeout bounds how long GetOrCompute and GetWithContext wait on a loader. Once timeout has passed, every
+// caller waiting on the loader gets context.DeadlineExceeded and nothing is cached, even if the loader returns a value
+// later, so a hanging backend can't hang every caller along with it. The loader's context is cancelled at the same
+// time, so loaders should return once it's done, as one that doesn't keeps running in the background. Defaults to
+// waiting for as long as the callers' own contexts allow. Timeouts that aren't positive are ignored.
+func WithLoaderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.loaderTimeout = timeout
+		}
+	}
+}
+
+// WithWriter writes values set in cache to writer, so the cache can front a durable store. Only values set by Set,
+// SetDefault, SetChecked, SetWithCost, SetWithSoftTTL, SetMulti, SetMultiWithTTLs, and SetManyWithPolicy with
+// OverwriteAlways are written, along with the values loaded by GetOrCompute, GetWithContext, and ReadThrough, which are
+// cached with Set. Every other change is only made in cache: conditional writes like SetNX, GetOrSet, Swap, Update,
+// CompareAndSwap, and SetManyWithPolicy with any other policy, counters like Increment and IncrementT, Batch, the hash,
+// list, and set commands, and every removal, including Delete and expiry. Keep the backing store in sync yourself if
+// you use any of them on keys it holds.
+//
+// With WriteThrough each value is written before it's cached, and if the write fails the value isn't cached, with
+// SetChecked returning the error. The cache isn't locked while writing, so concurrent sets of the same key may reach
+// writer in a different order than they're cached in.
+//
+// With WriteBehind each value is cached straight away, and written in the order it was cached by a background
+// goroutine. Failed writes are retried a couple of times with a backoff before being given up on, which is logged by
+// WithLogger. Stop writes anything still queued before returning. A nil writer is ignored.
+func WithWriter(writer Writer, mode WriteMode) Option {
+	return func(o *options) {
+		if writer != nil {
+			o.writer = writer
+			o.writeMode = mode
+		}
+	}
+}
+
+// WithRandSource sets the random source used to pick which expiring keys the garbage collecting ticker checks and to
+// jitter expiries, defaults to a source seeded from the time the cache is created. Passing a source with a fixed seed
+// makes both reproducible, which along with WithClock lets tests rely on which keys a tick evicts. The source is only
+// used by this cache, and clones get their own seeded from it. A nil source is ignored.
+func WithRandSource(source rand.Source) Option {
+	return func(o *options) {
+		if source != nil {
+			o.randSource = source
+		}
+	}
+}
+
+// WithRefreshAhead reloads keys before they expire, so hot keys don't all miss at once when their TTL runs out. Each
+// tick, any key expiring within threshold is passed to loader in the background, and the value it returns replaces
+// the key with its TTL restarted. If loader returns an error, or the key is removed in the meantime, the key is left
+// to expire as normal. Only one load runs per key at a time.
+//
+// Finding keys that are due means checking every key in cache on each tick, so it's best suited to smaller caches.
+// A threshold that isn't positive or a nil loader is ignored.
+func WithRefreshAhead(threshold time.Duration, loader func(key string) (interface{}, error)) Option {
+	return func(o *options) {
+		if threshold > 0 && loader != nil {
+			o.refreshThreshold = threshold
+			o.refreshLoader = loader
+		}
+	}
+}
+
+// WithMetricsCollector sends the cache's hits, misses, evictions, and size to collector as they happen, for exporting
+// to a monitoring system. Defaults to discarding them. A nil collector is ignored.
+func WithMetricsCollector(collector MetricsCollector) Option {
+	return func(o *options) {
+		if collector != nil {
+			o.metrics = collector
+		}
+	}
+}
+
+// WithStatsInterval calls fn with a snapshot of Stats every interval, for logging how effective the cache is. fn is
+// called from its own goroutine, which Stop waits on, so fn must not call Stop. An interval that isn't positive or a
+// nil fn is ignored.
+func WithStatsInterval(interval time.Duration, fn func(Stats)) Option {
+	return func(o *options) {
+		if interval > 0 && fn != nil {
+			o.statsInterval = interval
+			o.statsReporter = fn
+		}
+	}
+}
+
+// WithCompactionInterval shrinks the list of expiring keys every interval, if it has more than twice the room it
+// needs. The list never shrinks by itself, so after a burst of short lived keys it keeps the memory it needed at the
+// peak until it's compacted. Each shard's list is copied while its expiring keys are locked, which holds up setting
+// keys with an expiry in that shard but not lookups. Defaults to never compacting. Intervals that aren't positive are
+// ignored.
+func WithCompactionInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.compactionInterval = interval
+		}
+	}
+}
+
+// WithZeroTTLMeansImmediate makes an expiration of 0 mean the key expires immediately, as it does in many other caches,
+// rather than never. Keys set this way are stored already expired, so they're never returned and are removed by the
+// next lookup or garbage collection, counting as expired. Use NoExpiry to store keys without an expiry instead, which
+// also works without this option. This applies to every expiration passed to the cache, including to Expire and
+// WithDefaultTTL. Defaults to off, for compatibility.
+func WithZeroTTLMeansImmediate(enabled bool) Option {
+	return func(o *options) {
+		o.zeroTTLImmediate = enabled
+	}
+}
+
+// WithStartPaused stops New from starting the garbage collecting ticker, and the goroutine behind WithStatsInterval,
+// until Start is called. This suits caches created during init before the rest of the app is ready. Until then the
+// cache can be used as normal, but expired keys are only removed as they're looked up. Clones of a paused cache start
+// paused too. Defaults to off.
+func WithStartPaused(paused bool) Option {
+	return func(o *options) {
+		o.startPaused = paused
+	}
+}
+
+// WithMaxValueBytes guards against accidentally caching huge values, by rejecting values larger than n bytes. Every
+// method that sets a value ignores oversized ones and leaves the key as it was: conditional sets such as SetNX, Replace
+// and CompareAndSwap report the key as not set, GetOrSet, GetOrSetFunc and Swap return without storing the value, and
+// Update keeps the key's old value. SetChecked returns ErrValueTooLarge for them instead. Sizes are measured by
+// WithSizeFunc, which only knows the size of strings and byte slices by default, values of other types are always
+// allowed. Defaults to 0, which is unbounded. Bounds that aren't positive are ignored.
+func WithMaxValueBytes(n int64) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxValueBytes = n
+		}
+	}
+}
+
+// WithSizeFunc sets how WithMaxValueBytes and Size measure values, for caches holding types other than strings and
+// byte slices. A nil func is ignored.
+func WithSizeFunc(fn SizeFunc) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.sizeOf = fn
+		}
+	}
+}
+
+// WithLogger writes debug logs for diagnosing the cache's behaviour to logger, such as a summary of each garbage
+// collection tick, keys being overwritten, and errors from loaders passed to GetOrCompute and WithRefreshAhead.
+// Nothing is logged above debug level, and logs are only built when logger has debug enabled, so it costs next to
+// nothing otherwise. Logs are written outside of the cache's locks. Defaults to not logging. A nil logger is ignored.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithNegativeBloom keeps a bloom filter of every key in cache, so lookups through Get, Has, and the other Get variants
+// of keys that were never set return straight away without locking the store. This suits very large key spaces where
+// most lookups miss. size is the number of bits in the filter and hashes the number of bits set per key.
+//
+// A bloom filter can report a key that was never added, but never misses one that was, so a false positive just means
+// the store is checked as usual. The false positive rate grows with the number of keys, so size the filter for the
+// number of keys you expect: around 10 bits per key with 7 hashes gives a 1% false positive rate. Keys can't be removed
+// from a bloom filter, so it's rebuilt by the garbage collector once more keys have been removed than are in cache,
+// and by Clear, each of which checks every key in cache. A size or hashes of 0 is ignored.
+func WithNegativeBloom(size, hashes uint) Option {
+	return func(o *options) {
+		if size > 0 && hashes > 0 {
+			o.bloomSize = size
+			o.bloomHashes = hashes
+		}
+	}
+}
+
+// WithAccessTracking counts how often and how recently each key is found by a lookup, for TopAccessed and
+// BottomAccessed. Counts start from zero whenever a key is set. It costs an allocation per set and a little work per
+// hit, so it defaults to off.
+func WithAccessTracking(enabled bool) Option {
+	return func(o *options) {
+		o.accessTracking = enabled
+	}
+}
+
+// WithStaleWindow keeps keys in cache for window after they expire, so GetStale can still serve them while a backend is
+// unavailable. Every other lookup treats them as expired as usual, and they're evicted once the window has passed.
+// They still take up room until then, so they're counted by LenApprox and WithMaxKeys. Defaults to evicting keys as
+// soon as they expire. Keys set by SetWithSoftTTL use their hard deadline instead. Windows that aren't positive are
+// ignored.
+func WithStaleWindow(window time.Duration) Option {
+	return func(o *options) {
+		if window > 0 {
+			o.staleWindow = window
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package hotcache
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.tickInterval, defaultTickInterval)
+}
+
+func TestWithTickInterval(t *testing.T) {
+	cache := New(WithTickInterval(time.Millisecond * 10))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond)
+
+	time.Sleep(time.Millisecond * 50)
+
+	// The default interval wouldn't have ticked yet.
+	assert.Equal(t, expiringKeyCount(cache), 0)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestWithTickIntervalInvalid(t *testing.T) {
+	cache := New(WithTickInterval(0), WithTickInterval(-time.Second))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.tickInterval, defaultTickInterval)
+}
+
+func TestWithGCBatchSize(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithShards(1), WithGCBatchSize(10))
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Millisecond)
+	}
+	clock.Advance(time.Millisecond)
+
+	// Every key checked has expired, so each tick evicts exactly one batch.
+	cache.tick()
+	assert.Equal(t, expiringKeyCount(cache), 90)
+	cache.tick()
+	assert.Equal(t, expiringKeyCount(cache), 80)
+}
+
+func TestWithGCBatchSizeInvalid(t *testing.T) {
+	cache := New(WithGCBatchSize(0), WithGCBatchSize(-1))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.gcBatchSize, defaultGCBatchSize)
+}
+
+func TestWithCapacityHint(t *testing.T) {
+	cache := New(WithShards(4), WithCapacityHint(1000))
+	defer cache.Stop()
+
+	for _, s := range cache.shards {
+		assert.Equal(t, s.capacity, 250)
+		assert.Equal(t, cap(s.expiringKeys), 250)
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	cache.Clear()
+
+	for _, s := range cache.shards {
+		assert.Equal(t, cap(s.expiringKeys), 250)
+	}
+}
+
+func TestWithCapacityHintInvalid(t *testing.T) {
+	cache := New(WithCapacityHint(0), WithCapacityHint(-1))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.capacityHint, 0)
+	assert.Equal(t, cache.shards[0].capacity, 0)
+}
+
+func benchmarkLoad(b *testing.B, opts ...Option) {
+	keys := make([]string, 1000000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache := New(opts...)
+		for _, key := range keys {
+			cache.Set(key, i, time.Hour)
+		}
+		cache.Stop()
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	benchmarkLoad(b)
+}
+
+func BenchmarkLoadWithCapacityHint(b *testing.B) {
+	benchmarkLoad(b, WithCapacityHint(1000000))
+}
+
+func TestWithAdaptiveGC(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithShards(1), WithGCBatchSize(10), WithAdaptiveGC(true))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Millisecond)
+	}
+	clock.Advance(time.Millisecond)
+
+	// Every batch is fully expired, so each tick keeps checking batches up to the cap.
+	cache.tick()
+	assert.Equal(t, expiringKeyCount(cache), 1000-10*adaptiveGCMaxRounds)
+
+	for i := 0; i < 6; i++ {
+		cache.tick()
+	}
+	assert.Equal(t, expiringKeyCount(cache), 0)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestWithDefaultTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithDefaultTTL(time.Millisecond*10))
+	defer cache.Stop()
+
+	cache.SetDefault("xd", "xd")
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ok, true)
+	assert.True(t, ttl > 0 && ttl <= time.Millisecond*10)
+
+	// Explicit expirations aren't affected by the default.
+	cache.Set("xd2", "xd", 0)
+	cache.Set("xd3", "xd", time.Second)
+
+	clock.Advance(time.Millisecond * 10)
+
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Has("xd2"), true)
+	assert.Equal(t, cache.Has("xd3"), true)
+}
+
+func TestSetDefaultWithoutTTL(t *testing.T) {
+	cache := New(WithDefaultTTL(-time.Second))
+	defer cache.Stop()
+
+	cache.SetDefault("xd", "xd")
+
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+}
+
+func TestWithExpiryJitter(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithExpiryJitter(time.Second))
+	defer cache.Stop()
+
+	ttls := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		cache.Set(key, i, time.Minute)
+
+		ttl, _ := cache.TTL(key)
+		assert.True(t, ttl >= time.Minute && ttl < time.Minute+time.Second)
+		ttls[ttl] = struct{}{}
+	}
+	assert.True(t, len(ttls) > 1)
+
+	// Keys without an expiry aren't given one.
+	cache.Set("xd", "xd", 0)
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+}
+
+func TestWithExpiryJitterInvalid(t *testing.T) {
+	cache := New(WithExpiryJitter(-time.Second))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.expiryJitter, time.Duration(0))
+}
+
+func TestWithReadOptimized(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithReadOptimized(true))
+	defer cache.Stop()
+
+	_, ok := cache.Get("xd")
+	assert.Equal(t, ok, false)
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd2", time.Millisecond)
+	cache.SetMissing("xd3", 0)
+
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	val, ok = cache.Peek("xd2")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, cache.Has("xd3"), false)
+
+	cache.Set("xd", "xd4", 0)
+	val, _ = cache.Get("xd")
+	assert.Equal(t, val, "xd4")
+
+	cache.Delete("xd")
+	assert.Equal(t, cache.Has("xd"), false)
+
+	clock.Advance(time.Millisecond)
+	_, ok = cache.Peek("xd2")
+	assert.Equal(t, ok, false)
+
+	_, ok, expired := cache.GetDetailed("xd2")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, expired, true)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	cache.Clear()
+	assert.Equal(t, len(cache.shards[0].loadSnapshot()), 0)
+}
+
+func TestWithReadOptimizedConcurrent(t *testing.T) {
+	cache := New(WithReadOptimized(true), WithMaxKeys(50))
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := strconv.Itoa(i % 100)
+				if g%2 == 0 {
+					cache.Set(key, i, time.Millisecond)
+				} else {
+					cache.Get(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Every shard's snapshot matches its store once writes have stopped.
+	for _, s := range cache.shards {
+		assert.Equal(t, s.loadSnapshot(), s.store)
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Minute)
+
+	clock.Advance(time.Second * 59)
+	assert.Equal(t, cache.Has("xd"), true)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	cache.tick()
+	assert.Equal(t, expiringKeyCount(cache), 0)
+	assert.Equal(t, cache.LenApprox(), 0)
+}
+
+func TestWithClockNil(t *testing.T) {
+	cache := New(WithClock(nil))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.clock, Clock(realClock{}))
+}
+
+func TestWithRefreshAheadInvalid(t *testing.T) {
+	loader := func(key string) (interface{}, error) { return nil, nil }
+	cache := New(WithRefreshAhead(0, loader), WithRefreshAhead(time.Second, nil))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.refreshThreshold, time.Duration(0))
+	assert.Nil(t, cache.options.refreshLoader)
+}
+
+func TestZeroTTLDefault(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	// 0 and NoExpiry both mean no expiry by default.
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", "xd", NoExpiry)
+	cache.SetMulti(map[string]interface{}{"xd3": "xd"}, NoExpiry)
+	clock.Advance(time.Hour)
+
+	for _, key := range []string{"xd", "xd2", "xd3"} {
+		ttl, ok := cache.TTL(key)
+		assert.Equal(t, ttl, NoExpiry)
+		assert.Equal(t, ok, true)
+	}
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithZeroTTLMeansImmediate(t *testing.T) {
+	var reasons []EvictReason
+	cache := New(WithZeroTTLMeansImmediate(true), WithOnEvict(func(_ string, _ interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	_, ok := cache.Get("xd")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, reasons, []EvictReason{ReasonExpired})
+
+	cache.SetMulti(map[string]interface{}{"xd": "xd", "xd2": "xd"}, 0)
+	assert.Equal(t, cache.GetMulti([]string{"xd", "xd2"}), map[string]interface{}{})
+
+	// Expire with 0 expires the key too.
+	cache.Set("xd", "xd", time.Hour)
+	assert.Equal(t, cache.Expire("xd", 0), true)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	// NoExpiry still stores a key without one.
+	cache.Set("xd", "xd", NoExpiry)
+	ttl, ok := cache.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithZeroTTLMeansImmediateDefaults(t *testing.T) {
+	cache := New(WithZeroTTLMeansImmediate(true))
+	defer cache.Stop()
+
+	// Methods that don't take an expiration still store keys without one.
+	cache.SetDefault("xd", "xd")
+	cache.LoadOrStore("xd2", "xd")
+	cache.Increment("xd3", 1)
+	cache.SAdd("xd4", "xd")
+
+	for _, key := range []string{"xd", "xd2", "xd3", "xd4"} {
+		ttl, _ := cache.TTL(key)
+		assert.Equal(t, ttl, NoExpiry)
+	}
+
+	// Unless the default TTL is explicitly set to 0.
+	cache = New(WithZeroTTLMeansImmediate(true), WithDefaultTTL(0))
+	defer cache.Stop()
+
+	cache.SetDefault("xd", "xd")
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestWithStartPaused(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTickInterval(time.Millisecond), WithStartPaused(true))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Set("xd2", "xd", time.Second)
+	clock.Advance(time.Second)
+
+	// Nothing collects expired keys before Start.
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, cache.LenApprox(), 2)
+
+	// They're still evicted as they're looked up.
+	_, ok := cache.Get("xd")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	cache.Start()
+	cache.Start()
+	eventually(t, func() bool {
+		return cache.LenApprox() == 0
+	})
+}
+
+func TestWithStartPausedStop(t *testing.T) {
+	cache := New(WithStartPaused(true))
+
+	events, _ := cache.Subscribe()
+	cache.Set("xd", "xd", 0)
+
+	// Stop still clears a cache that was never started.
+	cache.Stop()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, drainEvents(events), []Event{
+		{Key: "xd", Type: EventSet, Value: "xd"},
+		{Key: "xd", Type: EventDeleted, Value: "xd"},
+	})
+}
+
+func TestWithRandSource(t *testing.T) {
+	// evictedBy returns the keys a cache evicts over a few ticks, with every key having expired.
+	evictedBy := func(source rand.Source) []string {
+		clock := newFakeClock()
+		recorder := &evictionRecorder{}
+		cache := New(
+			WithClock(clock),
+			WithRandSource(source),
+			WithShards(1),
+			WithGCBatchSize(3),
+			WithOnEvict(recorder.onEvict),
+			WithStartPaused(true),
+		)
+		defer cache.Stop()
+
+		for i := 0; i < 20; i++ {
+			cache.Set("xd"+strconv.Itoa(i), i, time.Second)
+		}
+		clock.Advance(time.Second)
+
+		for i := 0; i < 3; i++ {
+			cache.tick()
+		}
+
+		var keys []string
+		for _, e := range recorder.get() {
+			keys = append(keys, e.key)
+		}
+		return keys
+	}
+
+	first := evictedBy(rand.NewSource(1))
+	assert.Len(t, first, 9)
+	assert.Equal(t, evictedBy(rand.NewSource(1)), first)
+	assert.NotEqual(t, evictedBy(rand.NewSource(2)), first)
+}
+
+func TestWithRandSourceClone(t *testing.T) {
+	cache := New(WithRandSource(rand.NewSource(1)))
+	defer cache.Stop()
+
+	clone := cache.Clone()
+	defer clone.Stop()
+
+	assert.NotEqual(t, clone.options.randSource, cache.options.randSource)
+}
@@ -0,0 +1,125 @@
+package hotcache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// snapshotEntry is how a key is persisted by SaveToWriter, the expiry is absolute so time spent on disk counts
+// towards the key's TTL.
+type snapshotEntry struct {
+	Key    string
+	Value  interface{}
+	Expiry time.Time
+}
+
+// SaveToWriter writes every key that hasn't expired to w using encoding/gob, along with when each key expires. Values
+// are encoded as interface{}, so their concrete types must be registered with gob.Register before saving or loading,
+// unless they're one of gob's built in types.
+func (h *Hotcache) SaveToWriter(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(h.snapshot())
+}
+
+// LoadFromReader reads keys written by SaveToWriter into cache, overwriting any existing keys with the same name. Keys
+// that expired since they were saved are skipped.
+func (h *Hotcache) LoadFromReader(r io.Reader) error {
+	var entries []snapshotEntry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		h.restore(entry.Key, entry.Value, entry.Expiry)
+	}
+
+	return nil
+}
+
+// jsonEntry is how a key is represented by ExportJSON, ExpiresAt is left out for keys without an expiry.
+type jsonEntry struct {
+	Value     interface{} `json:"value"`
+	ExpiresAt *time.Time  `json:"expiresAt,omitempty"`
+}
+
+// ExportJSON encodes every key that hasn't expired as a JSON object, mapping each key to its value and when it
+// expires.
+func (h *Hotcache) ExportJSON() ([]byte, error) {
+	entries := h.snapshot()
+
+	exported := make(map[string]jsonEntry, len(entries))
+	for _, entry := range entries {
+		e := jsonEntry{Value: entry.Value}
+		if !entry.Expiry.IsZero() {
+			expiry := entry.Expiry
+			e.ExpiresAt = &expiry
+		}
+		exported[entry.Key] = e
+	}
+
+	return json.Marshal(exported)
+}
+
+// ImportJSON reads keys written by ExportJSON into cache, overwriting any existing keys with the same name. Keys that
+// have already expired are skipped.
+//
+// Values are decoded with encoding/json's defaults, so they won't necessarily come back as the type they were exported
+// as: numbers become float64, objects become map[string]interface{}, and arrays become []interface{}.
+func (h *Hotcache) ImportJSON(data []byte) error {
+	var imported map[string]jsonEntry
+	if err := json.Unmarshal(data, &imported); err != nil {
+		return err
+	}
+
+	for key, entry := range imported {
+		var expiry time.Time
+		if entry.ExpiresAt != nil {
+			expiry = *entry.ExpiresAt
+		}
+		h.restore(key, entry.Value, expiry)
+	}
+
+	return nil
+}
+
+// snapshot copies every key that hasn't expired, one shard at a time.
+func (h *Hotcache) snapshot() []snapshotEntry {
+	entries := make([]snapshotEntry, 0, h.LenApprox())
+
+	for _, s := range h.shards {
+		now := h.now()
+
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			entries = append(entries, snapshotEntry{Key: key, Value: val.value, Expiry: val.expiry})
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return entries
+}
+
+// restore stores a value that expires at an absolute time, the zero time meaning no expiry. Values that have already
+// expired are dropped.
+func (h *Hotcache) restore(key string, value interface{}, expiry time.Time) {
+	val := &cacheValue{
+		expiry: expiry,
+		value:  value,
+	}
+
+	if !expiry.IsZero() {
+		val.ttl = expiry.Sub(h.now())
+		if val.ttl <= 0 {
+			return
+		}
+	}
+
+	s := h.shard(key)
+	s.storeMutex.Lock()
+	h.setValue(s, key, val)
+	h.unlockStore(s)
+}
@@ -0,0 +1,138 @@
+package hotcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type persistedUser struct {
+	Name string
+	Age  int
+}
+
+func init() {
+	gob.Register(persistedUser{})
+}
+
+func TestSaveLoad(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", 10, time.Second)
+	cache.Set("user", persistedUser{Name: "xd", Age: 20}, 0)
+	cache.Set("expired", "xd", time.Millisecond*10)
+
+	clock.Advance(time.Millisecond * 10)
+
+	var buf bytes.Buffer
+	assert.Equal(t, cache.SaveToWriter(&buf), nil)
+
+	loaded := New(WithClock(clock))
+	defer loaded.Stop()
+	assert.Equal(t, loaded.LoadFromReader(&buf), nil)
+
+	assert.ElementsMatch(t, loaded.Keys(), []string{"xd", "xd2", "user"})
+
+	val, _ := loaded.Get("xd")
+	assert.Equal(t, val, "xd")
+	val, _ = loaded.Get("user")
+	assert.Equal(t, val, persistedUser{Name: "xd", Age: 20})
+
+	ttl, ok := loaded.TTL("xd")
+	assert.Equal(t, ttl, NoExpiry)
+	assert.Equal(t, ok, true)
+
+	originalTTL, _ := cache.TTL("xd2")
+	ttl, ok = loaded.TTL("xd2")
+	assert.Equal(t, ok, true)
+	assert.True(t, ttl > 0 && ttl <= originalTTL)
+	assert.Equal(t, expiringKeyCount(loaded), 1)
+}
+
+func TestLoadSkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	cache.Set("xd2", "xd2", 0)
+
+	var buf bytes.Buffer
+	assert.Equal(t, cache.SaveToWriter(&buf), nil)
+
+	// Expires while "on disk".
+	clock.Advance(time.Millisecond * 10)
+
+	loaded := New(WithClock(clock))
+	defer loaded.Stop()
+	assert.Equal(t, loaded.LoadFromReader(&buf), nil)
+
+	assert.Equal(t, loaded.Keys(), []string{"xd2"})
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.NotEqual(t, cache.LoadFromReader(bytes.NewBufferString("xd")), nil)
+}
+
+func TestExportImportJSON(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("string", "xd", 0)
+	cache.Set("number", 10, time.Second)
+	cache.Set("nested", map[string]interface{}{"name": "xd", "tags": []string{"a", "b"}}, 0)
+
+	data, err := cache.ExportJSON()
+	assert.Equal(t, err, nil)
+
+	loaded := New()
+	defer loaded.Stop()
+	assert.Equal(t, loaded.ImportJSON(data), nil)
+
+	val, _ := loaded.Get("string")
+	assert.Equal(t, val, "xd")
+
+	// JSON numbers and objects decode into their generic types.
+	val, _ = loaded.Get("number")
+	assert.Equal(t, val, float64(10))
+	val, _ = loaded.Get("nested")
+	assert.Equal(t, val, map[string]interface{}{"name": "xd", "tags": []interface{}{"a", "b"}})
+
+	ttl, _ := loaded.TTL("string")
+	assert.Equal(t, ttl, NoExpiry)
+	ttl, ok := loaded.TTL("number")
+	assert.Equal(t, ok, true)
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+}
+
+func TestImportJSONSkipsExpired(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339Nano)
+	future := time.Now().Add(time.Minute).Format(time.RFC3339Nano)
+	data := []byte(`{
+		"expired": {"value": "xd", "expiresAt": "` + past + `"},
+		"live": {"value": "xd", "expiresAt": "` + future + `"},
+		"forever": {"value": "xd"}
+	}`)
+
+	assert.Equal(t, cache.ImportJSON(data), nil)
+	assert.ElementsMatch(t, cache.Keys(), []string{"live", "forever"})
+}
+
+func TestImportJSONInvalid(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.NotEqual(t, cache.ImportJSON([]byte("xd")), nil)
+}
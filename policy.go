@@ -0,0 +1,44 @@
+package hotcache
+
+// EvictionPolicy decides which key a bounded cache evicts once it's full, see WithEvictionPolicy.
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evicts the least recently used key.
+	PolicyLRU EvictionPolicy = iota
+	// PolicyLFU evicts the least frequently used key, breaking ties by evicting the least recently used of them.
+	PolicyLFU
+)
+
+// String returns the name of the policy.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	default:
+		return "unknown"
+	}
+}
+
+// evictionTracker tracks how keys are used so a bounded cache can pick which to evict. Implementations have their own
+// lock, as Get only holds a read lock on the store while marking a key as used.
+type evictionTracker interface {
+	// add marks a key as used, tracking it if it's new.
+	add(key string)
+	// access marks an already tracked key as used.
+	access(key string)
+	// remove stops tracking a key.
+	remove(key string)
+	// victim returns the key that should be evicted next.
+	victim() (string, bool)
+}
+
+// newEvictionTracker creates the tracker for a policy.
+func newEvictionTracker(p EvictionPolicy) evictionTracker {
+	if p == PolicyLFU {
+		return newLFU()
+	}
+	return newLRU()
+}
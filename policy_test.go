@@ -0,0 +1,30 @@
+package hotcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPolicyString(t *testing.T) {
+	assert.Equal(t, PolicyLRU.String(), "lru")
+	assert.Equal(t, PolicyLFU.String(), "lfu")
+	assert.Equal(t, EvictionPolicy(100).String(), "unknown")
+}
+
+func TestWithEvictionPolicy(t *testing.T) {
+	cache := New(WithMaxKeys(1), WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+	_, ok := cache.tracker.(*lfu)
+	assert.Equal(t, ok, true)
+
+	cache = New(WithMaxKeys(1), WithEvictionPolicy(EvictionPolicy(100)))
+	defer cache.Stop()
+	_, ok = cache.tracker.(*lru)
+	assert.Equal(t, ok, true)
+
+	// Unbounded caches don't track usage at all.
+	cache = New(WithEvictionPolicy(PolicyLFU))
+	defer cache.Stop()
+	assert.Nil(t, cache.tracker)
+}
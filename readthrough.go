@@ -0,0 +1,51 @@
+package hotcache
+
+import (
+	"context"
+	"time"
+)
+
+// ReadThroughLoader loads the value of a key missing from a ReadThrough cache, along with the expiration to cache it
+// with, see Set.
+type ReadThroughLoader func(key string) (interface{}, time.Duration, error)
+
+// ReadThrough is a cache that loads every missing key with the same loader, so callers don't need to pass one to
+// GetOrCompute on every lookup.
+type ReadThrough struct {
+	cache  *Hotcache
+	loader ReadThroughLoader
+}
+
+// NewReadThrough creates a new read through cache that calls loader for keys that are missing or expired. Stop must be
+// called once you're done with it.
+func NewReadThrough(loader ReadThroughLoader, opts ...Option) *ReadThrough {
+	return &ReadThrough{
+		cache:  New(opts...),
+		loader: loader,
+	}
+}
+
+// Stop must be called when you are done with the cache, as it will stop the garbage collecting ticker.
+func (r *ReadThrough) Stop() {
+	r.cache.Stop()
+}
+
+// Get retrieves a key that isn't expired from cache, otherwise it's loaded and cached with the expiration the loader
+// returns. Like GetOrCompute, concurrent callers that miss on the same key share a single call to the loader, and
+// errors from it aren't cached and are returned to every caller.
+func (r *ReadThrough) Get(key string) (interface{}, error) {
+	return r.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext is Get with cancellation, if ctx is done before the key is loaded ctx.Err() is returned, see
+// Hotcache.GetWithContext.
+func (r *ReadThrough) GetWithContext(ctx context.Context, key string) (interface{}, error) {
+	return r.cache.getOrLoad(ctx, key, func(context.Context) (interface{}, time.Duration, error) {
+		return r.loader(key)
+	})
+}
+
+// Delete removes a key from cache, so it's loaded again by the next Get.
+func (r *ReadThrough) Delete(key string) {
+	r.cache.Delete(key)
+}
@@ -0,0 +1,115 @@
+package hotcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadThrough(t *testing.T) {
+	var loads int32
+	cache := NewReadThrough(func(key string) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&loads, 1)
+		return key + "-loaded", 0, nil
+	})
+	defer cache.Stop()
+
+	// Misses are loaded.
+	val, err := cache.Get("xd")
+	assert.Equal(t, val, "xd-loaded")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(1))
+
+	// Hits aren't.
+	val, err = cache.Get("xd")
+	assert.Equal(t, val, "xd-loaded")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(1))
+
+	// Deleted keys are loaded again.
+	cache.Delete("xd")
+	cache.Get("xd")
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(2))
+}
+
+func TestReadThroughTTL(t *testing.T) {
+	clock := newFakeClock()
+	var loads int32
+	cache := NewReadThrough(func(key string) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&loads, 1)
+		if key == "short" {
+			return key, time.Second, nil
+		}
+		return key, time.Minute, nil
+	}, WithClock(clock))
+	defer cache.Stop()
+
+	cache.Get("short")
+	cache.Get("long")
+
+	ttl, _ := cache.cache.TTL("short")
+	assert.Equal(t, ttl, time.Second)
+	ttl, _ = cache.cache.TTL("long")
+	assert.Equal(t, ttl, time.Minute)
+
+	// Only the key that expired is loaded again.
+	clock.Advance(time.Second)
+	cache.Get("short")
+	cache.Get("long")
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(3))
+}
+
+func TestReadThroughError(t *testing.T) {
+	loadErr := errors.New("backend down")
+	fail := true
+	cache := NewReadThrough(func(key string) (interface{}, time.Duration, error) {
+		if fail {
+			return nil, 0, loadErr
+		}
+		return "xd", 0, nil
+	})
+	defer cache.Stop()
+
+	val, err := cache.Get("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, err, loadErr)
+
+	// Errors aren't cached.
+	fail = false
+	val, err = cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+}
+
+func TestReadThroughDedup(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	cache := NewReadThrough(func(key string) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "xd", 0, nil
+	})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.Get("xd")
+			assert.Equal(t, val, "xd")
+			assert.Equal(t, err, nil)
+		}()
+	}
+
+	// Give every goroutine a chance to start waiting on the in-flight load.
+	time.Sleep(time.Millisecond * 20)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(1))
+}
@@ -0,0 +1,66 @@
+package hotcache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// refreshAhead reloads every key in a shard that expires within the refresh threshold, each in its own goroutine. It's
+// called by the ticker when WithRefreshAhead is set, and has to check every key in the shard.
+func (h *Hotcache) refreshAhead(s *shard) {
+	now := h.now()
+	deadline := now.Add(h.options.refreshThreshold)
+
+	type due struct {
+		key string
+		ttl time.Duration
+	}
+	var keys []due
+
+	s.storeMutex.RLock()
+	for key, val := range s.store {
+		if val.expiry.IsZero() || !val.live(now) || val.expiry.After(deadline) {
+			continue
+		}
+		keys = append(keys, due{key: key, ttl: val.ttl})
+	}
+	s.storeMutex.RUnlock()
+
+	for _, d := range keys {
+		if !h.startRefresh(d.key) {
+			continue
+		}
+		go h.refresh(d.key, d.ttl)
+	}
+}
+
+// startRefresh marks a key as being refreshed, returning false if it's already being refreshed.
+func (h *Hotcache) startRefresh(key string) bool {
+	h.refreshMutex.Lock()
+	defer h.refreshMutex.Unlock()
+
+	if _, ok := h.refreshing[key]; ok {
+		return false
+	}
+	h.refreshing[key] = struct{}{}
+	return true
+}
+
+// refresh calls the loader for a key and replaces its value with the result, restarting its TTL. If the loader fails,
+// or the key has been removed or expired in the meantime, the cache is left alone.
+func (h *Hotcache) refresh(key string, ttl time.Duration) {
+	defer func() {
+		h.refreshMutex.Lock()
+		delete(h.refreshing, key)
+		h.refreshMutex.Unlock()
+	}()
+
+	value, err := h.options.refreshLoader(key)
+	if err != nil {
+		if h.debugEnabled() {
+			h.debug("hotcache: refresh failed", slog.String("key", key), slog.Any("error", err))
+		}
+		return
+	}
+	h.Replace(key, value, ttl)
+}
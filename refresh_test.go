@@ -0,0 +1,121 @@
+package hotcache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	clock := newFakeClock()
+	var loads int32
+	cache := New(WithClock(clock), WithRefreshAhead(time.Millisecond*200, func(key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return key + "-fresh", nil
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Set("xd2", "xd2", time.Minute)
+	cache.Set("xd3", "xd3", 0)
+
+	// Nothing is due yet.
+	clock.Advance(time.Millisecond * 700)
+	cache.tick()
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(0))
+
+	clock.Advance(time.Millisecond * 150)
+	cache.tick()
+
+	eventually(t, func() bool {
+		val, _ := cache.Get("xd")
+		return val == "xd-fresh"
+	})
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(1))
+
+	// The refreshed key's TTL restarted, so it outlives its original expiry.
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Second)
+
+	clock.Advance(time.Millisecond * 500)
+	assert.Equal(t, cache.Has("xd"), true)
+
+	val, _ := cache.Get("xd2")
+	assert.Equal(t, val, "xd2")
+}
+
+func TestRefreshAheadError(t *testing.T) {
+	clock := newFakeClock()
+	loaded := make(chan struct{}, 1)
+	cache := New(WithClock(clock), WithRefreshAhead(time.Millisecond*200, func(key string) (interface{}, error) {
+		defer func() { loaded <- struct{}{} }()
+		return nil, errors.New("xd")
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+
+	clock.Advance(time.Millisecond * 900)
+	cache.tick()
+	<-loaded
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+
+	clock.Advance(time.Millisecond * 100)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestRefreshAheadOnePerKey(t *testing.T) {
+	clock := newFakeClock()
+	var loads int32
+	release := make(chan struct{})
+	cache := New(WithClock(clock), WithRefreshAhead(time.Millisecond*200, func(key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "fresh", nil
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+
+	clock.Advance(time.Millisecond * 900)
+	cache.tick()
+	cache.tick()
+	cache.tick()
+	close(release)
+
+	eventually(t, func() bool {
+		val, _ := cache.Get("xd")
+		return val == "fresh"
+	})
+	assert.Equal(t, atomic.LoadInt32(&loads), int32(1))
+}
+
+func TestRefreshAheadDeleted(t *testing.T) {
+	clock := newFakeClock()
+	loaded := make(chan struct{})
+	cache := New(WithClock(clock), WithRefreshAhead(time.Millisecond*200, func(key string) (interface{}, error) {
+		<-loaded
+		return "fresh", nil
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+
+	clock.Advance(time.Millisecond * 900)
+	cache.tick()
+	cache.Delete("xd")
+	close(loaded)
+
+	// A key deleted while it's being refreshed isn't brought back.
+	eventually(t, func() bool {
+		cache.refreshMutex.Lock()
+		defer cache.refreshMutex.Unlock()
+		return len(cache.refreshing) == 0
+	})
+	assert.Equal(t, cache.Has("xd"), false)
+}
@@ -0,0 +1,129 @@
+package hotcache
+
+// Sets are stored as a map[string]struct{} of their members. Like every other value, the map is never modified once
+// it's stored, so each change stores a new copy, and maps returned by Get must not be modified either.
+
+// SAdd adds members to the set stored at key and returns how many weren't already in it. Missing keys are created as
+// a new set with the default TTL from WithDefaultTTL, existing sets keep their expiry. If key holds a value that isn't
+// a set nothing is added and 0 is returned.
+func (h *Hotcache) SAdd(key string, members ...string) int {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	exists = exists && old.live(h.now())
+
+	var current map[string]struct{}
+	if exists {
+		set, ok := old.value.(map[string]struct{})
+		if !ok {
+			return 0
+		}
+		current = set
+	}
+
+	updated := make(map[string]struct{}, len(current)+len(members))
+	for member := range current {
+		updated[member] = struct{}{}
+	}
+
+	added := 0
+	for _, member := range members {
+		if _, ok := updated[member]; !ok {
+			updated[member] = struct{}{}
+			added++
+		}
+	}
+	if added == 0 {
+		return 0
+	}
+
+	if exists {
+		h.replaceValue(s, key, old, updated)
+	} else {
+		h.set(s, key, updated, h.options.defaultTTL)
+	}
+	return added
+}
+
+// SRem removes members from the set stored at key and returns how many were removed. The key is deleted once its set
+// is empty.
+func (h *Hotcache) SRem(key string, members ...string) int {
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	defer h.unlockStore(s)
+
+	old, exists := s.store[key]
+	if !exists || !old.live(h.now()) {
+		return 0
+	}
+
+	current, ok := old.value.(map[string]struct{})
+	if !ok {
+		return 0
+	}
+
+	updated := make(map[string]struct{}, len(current))
+	for member := range current {
+		updated[member] = struct{}{}
+	}
+
+	removed := 0
+	for _, member := range members {
+		if _, ok := updated[member]; ok {
+			delete(updated, member)
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		return 0
+	}
+
+	if len(updated) == 0 {
+		h.remove(s, key, old, ReasonDeleted)
+	} else {
+		h.replaceValue(s, key, old, updated)
+	}
+	return removed
+}
+
+// SMembers returns the members of the set stored at key in no particular order, or nil if key is missing, expired, or
+// isn't a set.
+func (h *Hotcache) SMembers(key string) []string {
+	set, ok := h.getSet(key)
+	if !ok {
+		return nil
+	}
+
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members
+}
+
+// SIsMember reports whether member is in the set stored at key.
+func (h *Hotcache) SIsMember(key, member string) bool {
+	set, ok := h.getSet(key)
+	if !ok {
+		return false
+	}
+
+	_, ok = set[member]
+	return ok
+}
+
+// getSet retrieves the set stored at key, it must not be modified.
+func (h *Hotcache) getSet(key string) (map[string]struct{}, bool) {
+	val, ok := h.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	set, ok := val.(map[string]struct{})
+	return set, ok
+}
@@ -0,0 +1,99 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSAdd(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SAdd("xd", "a", "b", "a"), 2)
+	assert.Equal(t, cache.SAdd("xd", "b", "c"), 1)
+	assert.Equal(t, cache.SAdd("xd", "c"), 0)
+	assert.ElementsMatch(t, cache.SMembers("xd"), []string{"a", "b", "c"})
+
+	// Adding nothing doesn't create the key.
+	assert.Equal(t, cache.SAdd("xd2"), 0)
+	assert.Equal(t, cache.Has("xd2"), false)
+}
+
+func TestSAddWrongType(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	assert.Equal(t, cache.SAdd("xd", "a"), 0)
+	assert.Equal(t, cache.SMembers("xd"), []string(nil))
+	assert.Equal(t, cache.SRem("xd", "a"), 0)
+	assert.Equal(t, cache.SIsMember("xd", "a"), false)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+}
+
+func TestSAddCopiesSet(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.SAdd("xd", "a")
+	before, _ := cache.Get("xd")
+
+	// Sets already handed out aren't modified by later changes.
+	cache.SAdd("xd", "b")
+	assert.Equal(t, before, map[string]struct{}{"a": {}})
+}
+
+func TestSRem(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SRem("xd", "a"), 0)
+
+	cache.SAdd("xd", "a", "b", "c")
+
+	assert.Equal(t, cache.SRem("xd", "a", "d"), 1)
+	assert.ElementsMatch(t, cache.SMembers("xd"), []string{"b", "c"})
+
+	// Removing the last members deletes the key.
+	assert.Equal(t, cache.SRem("xd", "b", "c"), 2)
+	assert.Equal(t, cache.Has("xd"), false)
+}
+
+func TestSIsMember(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SIsMember("xd", "a"), false)
+
+	cache.SAdd("xd", "a")
+
+	assert.Equal(t, cache.SIsMember("xd", "a"), true)
+	assert.Equal(t, cache.SIsMember("xd", "b"), false)
+}
+
+func TestSetExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithDefaultTTL(time.Second))
+	defer cache.Stop()
+
+	cache.SAdd("xd", "a")
+	clock.Advance(time.Millisecond * 600)
+
+	// Adding to an existing set keeps its expiry.
+	cache.SAdd("xd", "b")
+	ttl, _ := cache.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*400)
+
+	clock.Advance(time.Millisecond * 400)
+	assert.Equal(t, cache.SIsMember("xd", "a"), false)
+	assert.Equal(t, cache.SMembers("xd"), []string(nil))
+
+	// An expired set is replaced with a new one.
+	assert.Equal(t, cache.SAdd("xd", "a"), 1)
+	assert.ElementsMatch(t, cache.SMembers("xd"), []string{"a"})
+}
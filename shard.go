@@ -0,0 +1,180 @@
+package hotcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultShards = 16
+
+// shard holds a slice of the cache's keys, each shard has its own locks so operations on keys in different shards
+// don't contend with each other. This acts as a pool of locks keyed by hash: writes to the same key always serialize on
+// the same shard, while writes to unrelated keys usually land on different shards. Locking individual keys within a
+// shard wouldn't let more writes through, as changing the shard's map needs its lock regardless.
+type shard struct {
+	// Position of the shard in the cache's shards.
+	index int
+
+	// Adds thread-safety
+	expiryMutex sync.RWMutex
+	storeMutex  sync.RWMutex
+
+	// List of all keys that have an expiry on them, sampled at random by the expiry ticker.
+	expiringKeys []string
+
+	// Position of each key in expiringKeys, so keys can be removed from it in constant time.
+	expiringIndex map[string]int
+
+	// When each key in expiringKeys is due to be evicted with WithTimingWheel, nil otherwise. Guarded by expiryMutex.
+	wheel *timingWheel
+
+	// The actual cache store
+	store map[string]*cacheValue
+
+	// A copy of store that's replaced on every write when WithReadOptimized is set, so it can be read without locks.
+	// Values are never modified once stored, so the copy can share them with store.
+	snapshot atomic.Value
+
+	// Keys removed while the store mutex is held, waiting to be passed to the OnEvict callback.
+	evictions []eviction
+
+	// Changes made while the store mutex is held, waiting to be published to subscribers.
+	events []Event
+
+	// Number of keys the store and expiring keys are presized for, see WithCapacityHint.
+	capacity int
+}
+
+func newShard(index, capacity int) *shard {
+	return &shard{
+		index:         index,
+		expiringKeys:  make([]string, 0, capacity),
+		expiringIndex: make(map[string]int, capacity),
+		store:         make(map[string]*cacheValue, capacity),
+		capacity:      capacity,
+	}
+}
+
+// get assumes that the mutex lock has already been obtained. Tombstones set by SetMissing are reported as missing.
+func (s *shard) get(key string, now time.Time) (interface{}, bool, bool) {
+	val, ok := s.store[key]
+
+	if !ok {
+		return nil, ok, false
+	}
+
+	if val.expired(now) {
+		return nil, false, true
+	}
+
+	if val.negative {
+		return nil, false, false
+	}
+
+	return val.value, ok, false
+}
+
+// publishSnapshot replaces the shard's snapshot with a copy of its store, assumes the store mutex is held.
+func (s *shard) publishSnapshot() {
+	snapshot := make(map[string]*cacheValue, len(s.store))
+	for key, val := range s.store {
+		snapshot[key] = val
+	}
+	s.snapshot.Store(snapshot)
+}
+
+// loadSnapshot returns the shard's latest snapshot, which must not be modified.
+func (s *shard) loadSnapshot() map[string]*cacheValue {
+	snapshot, _ := s.snapshot.Load().(map[string]*cacheValue)
+	return snapshot
+}
+
+// trackExpiry adds keys to the list of expiring keys checked by the ticker, keys already in the list are skipped.
+func (s *shard) trackExpiry(keys ...string) {
+	s.expiryMutex.Lock()
+	for _, key := range keys {
+		if _, ok := s.expiringIndex[key]; ok {
+			continue
+		}
+		s.expiringIndex[key] = len(s.expiringKeys)
+		s.expiringKeys = append(s.expiringKeys, key)
+	}
+	s.expiryMutex.Unlock()
+}
+
+// untrackExpiry removes a key from the list of expiring keys by swapping it with the last key, if it's in the list.
+func (s *shard) untrackExpiry(key string) {
+	s.expiryMutex.Lock()
+	defer s.expiryMutex.Unlock()
+
+	index, ok := s.expiringIndex[key]
+	if !ok {
+		return
+	}
+
+	last := len(s.expiringKeys) - 1
+	if index != last {
+		moved := s.expiringKeys[last]
+		s.expiringKeys[index] = moved
+		s.expiringIndex[moved] = index
+	}
+	s.expiringKeys[last] = ""
+	s.expiringKeys = s.expiringKeys[:last]
+	delete(s.expiringIndex, key)
+
+	if s.wheel != nil {
+		s.wheel.unschedule(key)
+	}
+}
+
+// compactExpiry copies the list of expiring keys into a list that fits them, along with their index, if the list has
+// more than twice the room it needs. Neither shrinks by itself, so after many expiring keys have come and gone they
+// keep the memory they needed at their largest. It never shrinks them below the room saved by WithCapacityHint, and
+// returns whether it compacted them.
+func (s *shard) compactExpiry() bool {
+	s.expiryMutex.Lock()
+	defer s.expiryMutex.Unlock()
+
+	if cap(s.expiringKeys) <= len(s.expiringKeys)*2 || cap(s.expiringKeys) <= s.capacity {
+		return false
+	}
+
+	size := len(s.expiringKeys)
+	if size < s.capacity {
+		size = s.capacity
+	}
+
+	keys := make([]string, len(s.expiringKeys), size)
+	copy(keys, s.expiringKeys)
+	index := make(map[string]int, size)
+	for i, key := range keys {
+		index[key] = i
+	}
+
+	s.expiringKeys = keys
+	s.expiringIndex = index
+	return true
+}
+
+// resetExpiry empties the list of expiring keys.
+func (s *shard) resetExpiry() {
+	s.expiryMutex.Lock()
+	s.expiringKeys = make([]string, 0, s.capacity)
+	s.expiringIndex = make(map[string]int, s.capacity)
+	if s.wheel != nil {
+		s.wheel.reset()
+	}
+	s.expiryMutex.Unlock()
+}
+
+// shardIndex routes a key to a shard using the 32-bit FNV-1a hash of the key, it's inlined rather than using
+// hash/fnv to avoid allocating on every lookup.
+func shardIndex(key string, shards int) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(shards))
+}
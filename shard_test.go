@@ -0,0 +1,141 @@
+package hotcache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithShards(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+	assert.Equal(t, len(cache.shards), defaultShards)
+
+	cache2 := New(WithShards(4))
+	defer cache2.Stop()
+	assert.Equal(t, len(cache2.shards), 4)
+
+	cache3 := New(WithShards(0))
+	defer cache3.Stop()
+	assert.Equal(t, len(cache3.shards), defaultShards)
+}
+
+func TestShardRouting(t *testing.T) {
+	cache := New(WithShards(8))
+	defer cache.Stop()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), i, 0)
+	}
+
+	// Every key should end up in the shard its hash routes to, and the keys should be spread across all shards.
+	total := 0
+	for i, s := range cache.shards {
+		assert.NotEqual(t, len(s.store), 0)
+		for key := range s.store {
+			assert.Equal(t, shardIndex(key, len(cache.shards)), i)
+		}
+		total += len(s.store)
+	}
+	assert.Equal(t, total, 1000)
+	assert.Equal(t, cache.Len(), 1000)
+
+	for i := 0; i < 1000; i++ {
+		val, ok := cache.Get(strconv.Itoa(i))
+		assert.Equal(t, val, i)
+		assert.Equal(t, ok, true)
+	}
+}
+
+func TestShardIndexStable(t *testing.T) {
+	assert.Equal(t, shardIndex("xd", 16), shardIndex("xd", 16))
+	assert.Equal(t, shardIndex("", 16), int(uint32(2166136261)%16))
+}
+
+func TestTrackExpiry(t *testing.T) {
+	s := newShard(0, 0)
+
+	s.trackExpiry("a", "b", "c")
+	s.trackExpiry("a")
+	assert.Equal(t, s.expiringKeys, []string{"a", "b", "c"})
+
+	s.untrackExpiry("a")
+	assert.Equal(t, s.expiringKeys, []string{"c", "b"})
+	assert.Equal(t, s.expiringIndex, map[string]int{"c": 0, "b": 1})
+
+	s.untrackExpiry("b")
+	s.untrackExpiry("missing")
+	assert.Equal(t, s.expiringKeys, []string{"c"})
+	assert.Equal(t, s.expiringIndex, map[string]int{"c": 0})
+
+	s.resetExpiry()
+	assert.Equal(t, len(s.expiringKeys), 0)
+	assert.Equal(t, len(s.expiringIndex), 0)
+}
+
+func BenchmarkShards(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		for _, goroutines := range []int{8, 64} {
+			b.Run(fmt.Sprintf("shards=%d/goroutines=%d", shards, goroutines), func(b *testing.B) {
+				cache := New(WithShards(shards))
+				defer cache.Stop()
+
+				keys := make([]string, 1024)
+				for i := range keys {
+					keys[i] = strconv.Itoa(i)
+					cache.Set(keys[i], i, 0)
+				}
+
+				b.ResetTimer()
+
+				var wg sync.WaitGroup
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func(g int) {
+						defer wg.Done()
+						for i := g; i < b.N; i += goroutines {
+							key := keys[i%len(keys)]
+							if i%4 == 0 {
+								cache.Set(key, i, 0)
+							} else {
+								cache.Get(key)
+							}
+						}
+					}(g)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func BenchmarkDisjointWrites(b *testing.B) {
+	for _, shards := range []int{1, defaultShards, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			cache := New(WithShards(shards))
+			defer cache.Stop()
+
+			var next int64
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// Each goroutine writes to its own keys, so they only contend when their keys share a shard.
+				prefix := strconv.FormatInt(atomic.AddInt64(&next, 1), 10) + ":"
+				keys := make([]string, 64)
+				for i := range keys {
+					keys[i] = prefix + strconv.Itoa(i)
+				}
+
+				i := 0
+				for pb.Next() {
+					cache.Set(keys[i%len(keys)], i, 0)
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package hotcache
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrValueTooLarge is returned by SetChecked when a value is larger than the bound set by WithMaxValueBytes.
+var ErrValueTooLarge = errors.New("hotcache: value is too large")
+
+// SizeFunc estimates the size of a value in bytes, see WithSizeFunc. Sizes that aren't positive are treated as
+// unknown.
+type SizeFunc func(value interface{}) int64
+
+// defaultSizeOf measures strings and byte slices, which make up most large values, every other type is unknown.
+func defaultSizeOf(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	default:
+		return 0
+	}
+}
+
+// SetChecked is Set that returns ErrValueTooLarge rather than silently ignoring a value larger than the bound set by
+// WithMaxValueBytes, or the error from the Writer set by WithWriter when it fails to write the value with
+// WriteThrough. The key is left as it was when the value is rejected.
+func (h *Hotcache) SetChecked(key string, value interface{}, expiration time.Duration) error {
+	if h.tooLarge(value) {
+		return ErrValueTooLarge
+	}
+	if err := h.writeThrough(key, value); err != nil {
+		return err
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	h.set(s, key, value, expiration)
+	h.queueWrite(key, value)
+	h.unlockStore(s)
+	return nil
+}
+
+// tooLarge checks whether a value is over the bound set by WithMaxValueBytes.
+func (h *Hotcache) tooLarge(value interface{}) bool {
+	if h.options.maxValueBytes <= 0 {
+		return false
+	}
+	return h.options.sizeOf(value) > h.options.maxValueBytes
+}
+
+// Size returns how many keys in cache haven't expired along with the approximate number of bytes their values take up.
+// Each value is counted by its cost if it was set by SetWithCost, otherwise by the size from WithSizeFunc, falling back
+// to the size of its type as given by unsafe.Sizeof for numbers and booleans. Values of any other type count as 0, and
+// the memory used by keys and the cache itself isn't counted, so it's only an estimate suitable for capacity alerts.
+// Like Len, it has to check every key in cache.
+func (h *Hotcache) Size() (int, int64) {
+	now := h.now()
+	entries := 0
+	var bytes int64
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for _, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			entries++
+			bytes += h.sizeOfValue(val)
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return entries, bytes
+}
+
+// sizeOfValue estimates the size of a stored value in bytes, see Size.
+func (h *Hotcache) sizeOfValue(val *cacheValue) int64 {
+	if val.cost > 0 {
+		return val.cost
+	}
+	if size := h.options.sizeOf(val.value); size > 0 {
+		return size
+	}
+
+	// Type.Size is the same size unsafe.Sizeof reports.
+	t := reflect.TypeOf(val.value)
+	if t == nil {
+		return 0
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int64(t.Size())
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,207 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetChecked(t *testing.T) {
+	cache := New(WithMaxValueBytes(4))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetChecked("xd", "xdxd", 0), nil)
+	assert.Equal(t, cache.SetChecked("xd2", []byte("xd"), 0), nil)
+
+	// A rejected value leaves the key as it was.
+	assert.Equal(t, cache.SetChecked("xd", "xdxdx", 0), ErrValueTooLarge)
+	assert.Equal(t, cache.SetChecked("xd3", []byte("xdxdx"), 0), ErrValueTooLarge)
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xdxd")
+	assert.Equal(t, cache.Has("xd3"), false)
+
+	// Sizes of other types aren't known, so they're allowed.
+	assert.Equal(t, cache.SetChecked("xd4", 123456789, 0), nil)
+}
+
+func TestMaxValueBytesSet(t *testing.T) {
+	cache := New(WithMaxValueBytes(4))
+	defer cache.Stop()
+
+	cache.Set("xd", "xdxdx", 0)
+	cache.SetWithCost("xd2", "xdxdx", 1, 0)
+	cache.SetMulti(map[string]interface{}{"xd3": "xdxdx", "xd4": "xd"}, time.Second)
+	cache.SetMultiWithTTLs(map[string]ValueTTL{"xd5": {Value: "xdxdx", Expiration: time.Second}})
+
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd4"})
+	assertExpiryTracked(t, cache)
+}
+
+func TestMaxValueBytesSetVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing sets the key to "xd" first, for the variants that only set keys that exist.
+		existing bool
+		set      func(cache *Hotcache, value string)
+	}{
+		{"Set", false, func(cache *Hotcache, value string) { cache.Set("xd", value, 0) }},
+		{"SetDefault", false, func(cache *Hotcache, value string) { cache.SetDefault("xd", value) }},
+		{"SetChecked", false, func(cache *Hotcache, value string) { _ = cache.SetChecked("xd", value, 0) }},
+		{"SetWithCost", false, func(cache *Hotcache, value string) { cache.SetWithCost("xd", value, 1, 0) }},
+		{"SetMulti", false, func(cache *Hotcache, value string) {
+			cache.SetMulti(map[string]interface{}{"xd": value}, 0)
+		}},
+		{"SetMultiWithTTLs", false, func(cache *Hotcache, value string) {
+			cache.SetMultiWithTTLs(map[string]ValueTTL{"xd": {Value: value}})
+		}},
+		{"SetManyWithPolicy", false, func(cache *Hotcache, value string) {
+			cache.SetManyWithPolicy(map[string]interface{}{"xd": value}, 0, OverwriteAlways)
+		}},
+		{"SetWithSoftTTL", false, func(cache *Hotcache, value string) {
+			cache.SetWithSoftTTL("xd", value, time.Minute, time.Hour)
+		}},
+		{"SetNX", false, func(cache *Hotcache, value string) { cache.SetNX("xd", value, 0) }},
+		{"SetNXWithResult", false, func(cache *Hotcache, value string) { cache.SetNXWithResult("xd", value, 0) }},
+		{"SetIfVacant", false, func(cache *Hotcache, value string) { cache.SetIfVacant("xd", value, 0) }},
+		{"SetIfGreater", false, func(cache *Hotcache, value string) { cache.SetIfGreater("xd", value, 1, 0) }},
+		{"LoadOrStore", false, func(cache *Hotcache, value string) { cache.LoadOrStore("xd", value) }},
+		{"LoadOrStoreWithTTL", false, func(cache *Hotcache, value string) { cache.LoadOrStoreWithTTL("xd", value, 0) }},
+		{"GetOrSet", false, func(cache *Hotcache, value string) { cache.GetOrSet("xd", value, 0) }},
+		{"GetOrSetFunc", false, func(cache *Hotcache, value string) {
+			cache.GetOrSetFunc("xd", 0, func() interface{} { return value })
+		}},
+		{"Batch", false, func(cache *Hotcache, value string) { cache.Batch().Set("xd", value, 0).Exec() }},
+		{"Replace", true, func(cache *Hotcache, value string) { cache.Replace("xd", value, 0) }},
+		{"Swap", true, func(cache *Hotcache, value string) { cache.Swap("xd", value, 0) }},
+		{"CompareAndSwap", true, func(cache *Hotcache, value string) { cache.CompareAndSwap("xd", "xd", value, 0) }},
+		{"Update", true, func(cache *Hotcache, value string) {
+			cache.Update("xd", func(interface{}, bool) (interface{}, time.Duration, bool) { return value, 0, true })
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := New(WithMaxValueBytes(4))
+			defer cache.Stop()
+
+			if test.existing {
+				cache.Set("xd", "xd", 0)
+			}
+
+			// An oversized value leaves the key as it was.
+			test.set(cache, "xdxdx")
+			val, ok := cache.Get("xd")
+			if test.existing {
+				assert.Equal(t, val, "xd")
+			} else {
+				assert.Equal(t, ok, false)
+			}
+			assertExpiryTracked(t, cache)
+
+			// A value within the bound is set, so the variant is being tested.
+			test.set(cache, "xdxd")
+			val, _ = cache.Get("xd")
+			assert.Equal(t, val, "xdxd")
+		})
+	}
+}
+
+func TestMaxValueBytesResults(t *testing.T) {
+	cache := New(WithMaxValueBytes(4))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetNX("xd", "xdxdx", 0), false)
+	set, prior := cache.SetIfVacant("xd", "xdxdx", 0)
+	assert.Equal(t, set, false)
+	assert.Equal(t, prior, PriorAbsent)
+	actual, set := cache.SetNXWithResult("xd", "xdxdx", 0)
+	assert.Equal(t, actual, nil)
+	assert.Equal(t, set, false)
+	actual, loaded := cache.GetOrSet("xd", "xdxdx", 0)
+	assert.Equal(t, actual, "xdxdx")
+	assert.Equal(t, loaded, false)
+
+	cache.Set("xd", "xd", 0)
+	assert.Equal(t, cache.Replace("xd", "xdxdx", 0), false)
+	assert.Equal(t, cache.CompareAndSwap("xd", "xd", "xdxdx", 0), false)
+	old, existed := cache.Swap("xd", "xdxdx", 0)
+	assert.Equal(t, old, "xd")
+	assert.Equal(t, existed, true)
+}
+
+func TestWithSizeFunc(t *testing.T) {
+	cache := New(WithMaxValueBytes(10), WithSizeFunc(func(value interface{}) int64 {
+		if values, ok := value.([]int); ok {
+			return int64(len(values) * 8)
+		}
+		return 0
+	}))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetChecked("xd", []int{1}, 0), nil)
+	assert.Equal(t, cache.SetChecked("xd", []int{1, 2}, 0), ErrValueTooLarge)
+
+	// Strings aren't measured by the custom func.
+	assert.Equal(t, cache.SetChecked("xd", "xdxdxdxdxdxd", 0), nil)
+}
+
+func TestMaxValueBytesUnbounded(t *testing.T) {
+	cache := New(WithMaxValueBytes(0), WithSizeFunc(nil))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.SetChecked("xd", string(make([]byte, 1<<20)), 0), nil)
+	assert.Equal(t, cache.Has("xd"), true)
+}
+
+func TestSize(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	entries, bytes := cache.Size()
+	assert.Equal(t, entries, 0)
+	assert.Equal(t, bytes, int64(0))
+
+	cache.Set("small", make([]byte, 10), 0)
+	entries, bytes = cache.Size()
+	assert.Equal(t, entries, 1)
+	assert.Equal(t, bytes, int64(10))
+
+	cache.Set("large", make([]byte, 1000), 0)
+	entries, bytes = cache.Size()
+	assert.Equal(t, entries, 2)
+	assert.Equal(t, bytes, int64(1010))
+
+	// Costs take precedence, numbers fall back to the size of their type, and unknown types count as 0.
+	cache.SetWithCost("cost", make([]byte, 1000), 5, 0)
+	cache.Set("int64", int64(1), 0)
+	cache.Set("struct", struct{}{}, 0)
+	entries, bytes = cache.Size()
+	assert.Equal(t, entries, 5)
+	assert.Equal(t, bytes, int64(1023))
+
+	// Expired keys aren't counted.
+	cache.Set("expiring", make([]byte, 100), time.Second)
+	clock.Advance(time.Second)
+	entries, bytes = cache.Size()
+	assert.Equal(t, entries, 5)
+	assert.Equal(t, bytes, int64(1023))
+}
+
+func TestSizeWithSizeFunc(t *testing.T) {
+	cache := New(WithSizeFunc(func(value interface{}) int64 {
+		if v, ok := value.([]int); ok {
+			return int64(len(v) * 8)
+		}
+		return 0
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", []int{1, 2, 3}, 0)
+	cache.Set("xd2", []byte("xd"), 0)
+
+	_, bytes := cache.Size()
+	assert.Equal(t, bytes, int64(24))
+}
@@ -0,0 +1,117 @@
+package hotcache
+
+import "time"
+
+// SetWithSoftTTL adds a key to store that goes stale after soft and is evicted after hard, for serving stale values
+// while they're revalidated. Get and every other lookup treat the key as expired once it's stale, only GetAllowStale
+// and GetStale keep serving it until hard, after which they miss and evict it. A hard shorter than soft is treated as
+// soft, and a soft of NoExpiry means the key never goes stale, ignoring hard. A soft of 0 is the same as NoExpiry
+// unless WithZeroTTLMeansImmediate is set, which makes the key stale straight away, but still served by GetAllowStale
+// and GetStale until hard. Changing the key's expiry afterwards, such as with Expire, drops the hard deadline, while
+// Touch keeps the same stale period after the key's new expiry. Like Set, values larger than WithMaxValueBytes allows
+// are ignored, and values are written to the Writer set by WithWriter.
+func (h *Hotcache) SetWithSoftTTL(key string, value interface{}, soft, hard time.Duration) {
+	if h.tooLarge(value) || h.writeThrough(key, value) != nil {
+		return
+	}
+
+	val := h.newValue(value, soft)
+	if !val.expiry.IsZero() {
+		val.hard = h.now().Add(hard)
+		if val.hard.Before(val.expiry) {
+			val.hard = val.expiry
+		}
+	}
+
+	s := h.shard(key)
+
+	s.storeMutex.Lock()
+	h.setValue(s, key, val)
+	h.queueWrite(key, value)
+	h.unlockStore(s)
+}
+
+// GetAllowStale retrieves a key like Get, but also serves it once it's stale until it's due to be evicted, which is
+// the hard deadline of keys set by SetWithSoftTTL or the WithStaleWindow otherwise. Keys past that are evicted.
+func (h *Hotcache) GetAllowStale(key string) (interface{}, bool) {
+	s := h.shard(key)
+	now := h.now()
+
+	s.storeMutex.RLock()
+	val, ok := s.store[key]
+	s.storeMutex.RUnlock()
+
+	if ok && h.evictable(val, now) {
+		h.recordLookup(false)
+		s.storeMutex.Lock()
+		h.evict(s, key)
+		h.unlockStore(s)
+		return nil, false
+	}
+
+	if !ok || val.negative {
+		h.recordLookup(false)
+		return nil, false
+	}
+
+	if !val.expired(now) {
+		h.access(key, val)
+	}
+	h.recordLookup(true)
+	return val.value, true
+}
+
+// GetStale retrieves a key like Get, but also serves it once it's expired for as long as it's kept around by
+// SetWithSoftTTL or WithStaleWindow, so a stale value can be used rather than nothing while a backend is unavailable.
+// stale reports whether the value has expired. Like GetAllowStale, keys past that are evicted, so without either an
+// expired key is missed the same as with Get. ok is false if the key was never set or has since been evicted.
+func (h *Hotcache) GetStale(key string) (value interface{}, stale bool, ok bool) {
+	s := h.shard(key)
+	now := h.now()
+
+	s.storeMutex.RLock()
+	val, ok := s.store[key]
+	s.storeMutex.RUnlock()
+
+	if ok && h.evictable(val, now) {
+		h.recordLookup(false)
+		s.storeMutex.Lock()
+		h.evict(s, key)
+		h.unlockStore(s)
+		return nil, false, false
+	}
+
+	if !ok || val.negative {
+		h.recordLookup(false)
+		return nil, false, false
+	}
+
+	stale = val.expired(now)
+	if !stale {
+		h.access(key, val)
+	}
+	h.recordLookup(!stale)
+
+	return val.value, stale, true
+}
+
+// evictionTime returns when a value with an expiry can be evicted, see evictable.
+func (h *Hotcache) evictionTime(val *cacheValue) time.Time {
+	if !val.hard.IsZero() {
+		return val.hard
+	}
+	return val.expiry.Add(h.options.staleWindow)
+}
+
+// evictable checks whether a value has expired and is past its hard deadline or the window set by WithStaleWindow, so
+// it can be evicted.
+func (h *Hotcache) evictable(val *cacheValue, now time.Time) bool {
+	switch {
+	case !val.expired(now):
+		return false
+	case !val.hard.IsZero():
+		return !val.hard.After(now)
+	default:
+		return h.options.staleWindow <= 0 || !val.expiry.Add(h.options.staleWindow).After(now)
+	}
+}
@@ -0,0 +1,287 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStale(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithStaleWindow(time.Second))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	// Live values aren't stale.
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, true)
+
+	// Once expired, they're kept around for the window.
+	clock.Advance(time.Millisecond * 10)
+	cache.tick()
+
+	_, ok = cache.Get("xd")
+	assert.Equal(t, ok, false)
+
+	val, stale, ok = cache.GetStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, stale, true)
+	assert.Equal(t, ok, true)
+
+	// They're evicted once the window has passed.
+	clock.Advance(time.Second)
+	cache.tick()
+
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+
+	val, stale, ok = cache.GetStale("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, false)
+}
+
+func TestGetStaleMissing(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, false)
+
+	// Tombstones aren't served either.
+	cache.SetMissing("xd", 0)
+	_, _, ok = cache.GetStale("xd")
+	assert.Equal(t, ok, false)
+}
+
+func TestGetStaleWithoutWindow(t *testing.T) {
+	clock := newFakeClock()
+	recorder := &evictionRecorder{}
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	// Nothing keeps expired keys around, so they're evicted like Get does.
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonExpired}})
+	assertExpiryTracked(t, cache)
+}
+
+func TestGetStalePastWindow(t *testing.T) {
+	clock := newFakeClock()
+	recorder := &evictionRecorder{}
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict), WithStaleWindow(time.Second), WithStartPaused(true))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+
+	// The ticker is paused, so only GetStale can evict the key once the window has passed.
+	clock.Advance(time.Millisecond*10 + time.Second)
+
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 0)
+	assert.Equal(t, recorder.get(), []eviction{{key: "xd", value: "xd", reason: ReasonExpired}})
+	assertExpiryTracked(t, cache)
+}
+
+func TestStaleWindowReads(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithStaleWindow(time.Second))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	// Other lookups report stale keys as expired, without evicting them.
+	_, ok, expired := cache.GetDetailed("xd")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, expired, true)
+	assert.Equal(t, cache.Has("xd"), false)
+	assert.Equal(t, cache.Len(), 0)
+	assert.Equal(t, cache.LenApprox(), 1)
+
+	// Setting the key again replaces the stale value.
+	cache.Set("xd", "xd2", 0)
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, true)
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetWithSoftTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.SetWithSoftTTL("xd", "xd", time.Second, time.Second*5)
+
+	// Fresh.
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	val, ok = cache.GetAllowStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	// Stale, the ticker leaves it alone.
+	clock.Advance(time.Second)
+	cache.tick()
+
+	_, ok = cache.Get("xd")
+	assert.Equal(t, ok, false)
+	val, ok = cache.GetAllowStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	_, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, stale, true)
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second * 3)
+	cache.tick()
+	_, ok = cache.GetAllowStale("xd")
+	assert.Equal(t, ok, true)
+
+	// Expired, the ticker evicts it.
+	clock.Advance(time.Second)
+	cache.tick()
+
+	assert.Equal(t, cache.LenApprox(), 0)
+	_, ok = cache.GetAllowStale("xd")
+	assert.Equal(t, ok, false)
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetWithSoftTTLGetStale(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithStartPaused(true))
+	defer cache.Stop()
+
+	cache.SetWithSoftTTL("xd", "xd", time.Second, time.Second*2)
+
+	clock.Advance(time.Second)
+	val, stale, ok := cache.GetStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, stale, true)
+	assert.Equal(t, ok, true)
+
+	// Past the hard deadline it's evicted, even though the ticker hasn't run.
+	clock.Advance(time.Hour)
+	val, stale, ok = cache.GetStale("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, stale, false)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetWithSoftTTLZero(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	// Without WithZeroTTLMeansImmediate the key never goes stale.
+	cache.SetWithSoftTTL("xd", "xd", 0, time.Second)
+	clock.Advance(time.Hour)
+	val, ok := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	immediate := New(WithClock(clock), WithZeroTTLMeansImmediate(true))
+	defer immediate.Stop()
+
+	// With it the key is stale straight away, but served until hard.
+	immediate.SetWithSoftTTL("xd", "xd", 0, time.Second)
+	_, ok = immediate.Get("xd")
+	assert.Equal(t, ok, false)
+	val, ok = immediate.GetAllowStale("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+	_, ok = immediate.GetAllowStale("xd")
+	assert.Equal(t, ok, false)
+	assertExpiryTracked(t, immediate)
+}
+
+func TestGetAllowStaleEvicts(t *testing.T) {
+	clock := newFakeClock()
+	recorder := &evictionRecorder{}
+	cache := New(WithClock(clock), WithOnEvict(recorder.onEvict), WithStaleWindow(time.Second))
+	defer cache.Stop()
+
+	cache.SetWithSoftTTL("xd", "xd", time.Second, time.Second*2)
+	cache.Set("xd2", "xd2", time.Second)
+
+	// Keys set without a hard deadline use the stale window.
+	clock.Advance(time.Second)
+	val, ok := cache.GetAllowStale("xd2")
+	assert.Equal(t, val, "xd2")
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+	_, ok = cache.GetAllowStale("xd")
+	assert.Equal(t, ok, false)
+	_, ok = cache.GetAllowStale("xd2")
+	assert.Equal(t, ok, false)
+
+	assert.ElementsMatch(t, recorder.get(), []eviction{
+		{key: "xd", value: "xd", reason: ReasonExpired},
+		{key: "xd2", value: "xd2", reason: ReasonExpired},
+	})
+	assertExpiryTracked(t, cache)
+}
+
+func TestSetWithSoftTTLDeadlines(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	// A hard shorter than soft leaves no stale period.
+	cache.SetWithSoftTTL("xd", "xd", time.Second, time.Millisecond)
+	// Without a soft TTL the key never goes stale.
+	cache.SetWithSoftTTL("xd2", "xd2", 0, time.Millisecond)
+	// Changing the expiry drops the hard deadline.
+	cache.SetWithSoftTTL("xd3", "xd3", time.Second, time.Second*5)
+	cache.Expire("xd3", time.Second)
+	// Touch keeps the stale period.
+	cache.SetWithSoftTTL("xd4", "xd4", time.Second, time.Second*2)
+
+	clock.Advance(time.Millisecond * 500)
+	cache.Touch("xd4")
+
+	clock.Advance(time.Millisecond * 500)
+	_, ok := cache.GetAllowStale("xd")
+	assert.Equal(t, ok, false)
+
+	clock.Advance(time.Millisecond * 500)
+	_, ok = cache.GetAllowStale("xd3")
+	assert.Equal(t, ok, false)
+	_, ok = cache.Get("xd4")
+	assert.Equal(t, ok, false)
+	_, ok = cache.GetAllowStale("xd4")
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+	_, ok = cache.GetAllowStale("xd4")
+	assert.Equal(t, ok, false)
+
+	ttl, _ := cache.TTL("xd2")
+	assert.Equal(t, ttl, NoExpiry)
+	assertExpiryTracked(t, cache)
+}
@@ -0,0 +1,204 @@
+package hotcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// debugMaxEntries caps how many keys Debug lists.
+	debugMaxEntries = 20
+	// debugMaxValueLength caps how many bytes of each value Debug prints.
+	debugMaxValueLength = 64
+)
+
+// Stats is a snapshot of how effective the cache has been.
+type Stats struct {
+	// Hits is the number of Get and Has calls that found a key.
+	Hits uint64
+	// Misses is the number of Get and Has calls that didn't find a key.
+	Misses uint64
+	// Evictions is the number of keys removed from cache, whether they expired, were deleted, flushed, or evicted to
+	// make room. Keys that are overwritten aren't counted.
+	Evictions uint64
+	// Sets is the number of values written to cache.
+	Sets uint64
+	// DroppedEvents is the number of events that weren't delivered because a subscriber's buffer was full, see
+	// Subscribe.
+	DroppedEvents uint64
+}
+
+// HitRatio returns the fraction of lookups that were hits, or 0 if there haven't been any lookups.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// stats holds the live counters behind Stats, they're updated atomically so reading them doesn't contend with the
+// store mutex.
+type stats struct {
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	sets          uint64
+	droppedEvents uint64
+}
+
+// Stats returns a snapshot of the cache's hit, miss, eviction, and set counters.
+func (h *Hotcache) Stats() Stats {
+	return Stats{
+		Hits:          atomic.LoadUint64(&h.stats.hits),
+		Misses:        atomic.LoadUint64(&h.stats.misses),
+		Evictions:     atomic.LoadUint64(&h.stats.evictions),
+		Sets:          atomic.LoadUint64(&h.stats.sets),
+		DroppedEvents: atomic.LoadUint64(&h.stats.droppedEvents),
+	}
+}
+
+// ResetStats zeroes every counter behind Stats, for example to measure the steady state hit ratio once the cache has
+// been warmed. Counts already sent to a collector from WithMetricsCollector aren't affected.
+func (h *Hotcache) ResetStats() {
+	atomic.StoreUint64(&h.stats.hits, 0)
+	atomic.StoreUint64(&h.stats.misses, 0)
+	atomic.StoreUint64(&h.stats.evictions, 0)
+	atomic.StoreUint64(&h.stats.sets, 0)
+	atomic.StoreUint64(&h.stats.droppedEvents, 0)
+}
+
+// ExpiryHistogram counts how long the keys in cache have left until they expire, for tuning TTLs. Each key that hasn't
+// expired is counted under the largest bucket its remaining TTL is at least, or under 0 if it's shorter than every
+// bucket, so buckets of 1s and 1m count keys with under a second left under 0, up to a minute under 1s, and the rest
+// under 1m. Keys without an expiry are counted under NoExpiry. Buckets can be passed in any order, ones that aren't
+// positive are ignored. Like Len, it has to check every key in cache, though each shard is only locked once.
+func (h *Hotcache) ExpiryHistogram(buckets []time.Duration) map[time.Duration]int {
+	bounds := make([]time.Duration, 0, len(buckets))
+	for _, b := range buckets {
+		if b > 0 {
+			bounds = append(bounds, b)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	now := h.now()
+	histogram := make(map[time.Duration]int)
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for _, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			if val.expiry.IsZero() {
+				histogram[NoExpiry]++
+				continue
+			}
+
+			remaining := val.expiry.Sub(now)
+			// The first bound the remaining TTL is short of, the bucket it's counted under is the one before it.
+			i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > remaining })
+			if i == 0 {
+				histogram[0]++
+			} else {
+				histogram[bounds[i-1]]++
+			}
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	return histogram
+}
+
+// Debug returns a human readable summary of the cache for inspecting it while debugging: how many keys it holds and how
+// many of them expire, how often it checks for expired keys, then up to 20 of its keys sorted, each with its value
+// and how long it has left. Values are formatted with %v and cut short if they're long. The format isn't stable, so
+// it shouldn't be parsed.
+func (h *Hotcache) Debug() string {
+	type entry struct {
+		key string
+		val *cacheValue
+	}
+
+	now := h.now()
+	total, expiring := 0, 0
+	entries := make([]entry, 0, debugMaxEntries)
+
+	for _, s := range h.shards {
+		s.storeMutex.RLock()
+		for key, val := range s.store {
+			if !val.live(now) {
+				continue
+			}
+			total++
+			if !val.expiry.IsZero() {
+				expiring++
+			}
+			if len(entries) < debugMaxEntries {
+				entries = append(entries, entry{key: key, val: val})
+			}
+		}
+		s.storeMutex.RUnlock()
+	}
+
+	// Values are never modified once they're stored, so they can be formatted without holding the lock.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "hotcache: %d keys, %d with a ttl, tick interval %s\n", total, expiring, h.options.tickInterval)
+	for _, e := range entries {
+		value := fmt.Sprintf("%v", e.val.value)
+		if len(value) > debugMaxValueLength {
+			// Cut at the start of a rune, so multi-byte characters aren't split.
+			cut := debugMaxValueLength
+			for cut > 0 && !utf8.RuneStart(value[cut]) {
+				cut--
+			}
+			value = value[:cut] + "..."
+		}
+
+		ttl := "no expiry"
+		if !e.val.expiry.IsZero() {
+			ttl = "ttl " + e.val.expiry.Sub(now).String()
+		}
+		fmt.Fprintf(&b, "  %q = %s (%s)\n", e.key, value, ttl)
+	}
+	if total > len(entries) {
+		fmt.Fprintf(&b, "  ... and %d more\n", total-len(entries))
+	}
+
+	return b.String()
+}
+
+// reportStats passes a snapshot of Stats to the reporter from WithStatsInterval every interval until done is closed.
+func (h *Hotcache) reportStats(done <-chan struct{}) {
+	defer h.background.Done()
+
+	ticker := time.NewTicker(h.options.statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.options.statsReporter(h.Stats())
+		case <-done:
+			return
+		}
+	}
+}
+
+// recordLookup counts a Get or Has call as a hit or a miss.
+func (h *Hotcache) recordLookup(hit bool) {
+	if hit {
+		atomic.AddUint64(&h.stats.hits, 1)
+		h.options.metrics.IncHit()
+	} else {
+		atomic.AddUint64(&h.stats.misses, 1)
+		h.options.metrics.IncMiss()
+	}
+}
@@ -0,0 +1,186 @@
+package hotcache
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.Stats(), Stats{})
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd", "xd2", 0)
+	cache.Set("xd2", "xd", time.Millisecond*10)
+
+	cache.Get("xd")
+	cache.Has("xd")
+	cache.Get("xd3")
+	cache.Has("xd3")
+	cache.Get("xd3")
+
+	cache.Delete("xd")
+
+	clock.Advance(time.Millisecond * 10)
+	cache.tick()
+
+	assert.Equal(t, cache.Stats(), Stats{
+		Hits:      2,
+		Misses:    3,
+		Evictions: 2,
+		Sets:      3,
+	})
+}
+
+func TestStatsHitRatio(t *testing.T) {
+	assert.Equal(t, Stats{}.HitRatio(), float64(0))
+	assert.Equal(t, Stats{Hits: 3, Misses: 1}.HitRatio(), 0.75)
+	assert.Equal(t, Stats{Misses: 4}.HitRatio(), float64(0))
+}
+
+func TestResetStats(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Get("xd")
+	cache.Get("xd2")
+	cache.Delete("xd")
+
+	cache.ResetStats()
+	assert.Equal(t, cache.Stats(), Stats{})
+
+	// Counting carries on as normal afterwards.
+	cache.Set("xd", "xd", 0)
+	cache.Get("xd")
+	assert.Equal(t, cache.Stats(), Stats{Hits: 1, Sets: 1})
+}
+
+func TestWithStatsInterval(t *testing.T) {
+	reports := make(chan Stats, 1)
+	cache := New(WithStatsInterval(time.Millisecond, func(stats Stats) {
+		select {
+		case reports <- stats:
+		default:
+		}
+	}))
+
+	cache.Set("xd", "xd", 0)
+	cache.Get("xd")
+
+	// Reports are taken every interval, so wait for one that includes the lookup.
+	for stats := range reports {
+		if stats.Hits == 1 {
+			assert.Equal(t, stats, Stats{Hits: 1, Sets: 1})
+			break
+		}
+	}
+	cache.Stop()
+}
+
+func TestWithStatsIntervalStop(t *testing.T) {
+	var calls int64
+	cache := New(WithStatsInterval(time.Millisecond, func(Stats) {
+		atomic.AddInt64(&calls, 1)
+	}))
+
+	time.Sleep(time.Millisecond * 20)
+	cache.Stop()
+
+	// Stop waits for the reporting goroutine to exit, so nothing is reported afterwards.
+	stopped := atomic.LoadInt64(&calls)
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, atomic.LoadInt64(&calls), stopped)
+
+	// Stopping twice is fine.
+	cache.Stop()
+}
+
+func TestWithStatsIntervalInvalid(t *testing.T) {
+	o := defaultOptions()
+	WithStatsInterval(0, func(Stats) {})(&o)
+	WithStatsInterval(time.Second, nil)(&o)
+	assert.Equal(t, o.statsInterval, time.Duration(0))
+	assert.Nil(t, o.statsReporter)
+}
+
+func TestExpiryHistogram(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Millisecond*500)
+	cache.Set("xd2", "xd", time.Second)
+	cache.Set("xd3", "xd", time.Second*30)
+	cache.Set("xd4", "xd", time.Minute)
+	cache.Set("xd5", "xd", time.Hour)
+	cache.Set("xd6", "xd", 0)
+	cache.Set("xd7", "xd", 0)
+	cache.Set("expired", "xd", time.Millisecond*10)
+	cache.SetMissing("missing", time.Hour)
+
+	clock.Advance(time.Millisecond * 10)
+
+	buckets := []time.Duration{time.Minute, time.Second, 0, time.Second}
+	assert.Equal(t, cache.ExpiryHistogram(buckets), map[time.Duration]int{
+		0:           2,
+		time.Second: 2,
+		time.Minute: 1,
+		NoExpiry:    2,
+	})
+
+	// Remaining TTLs shrink as time passes.
+	clock.Advance(time.Second)
+	assert.Equal(t, cache.ExpiryHistogram(buckets), map[time.Duration]int{
+		time.Second: 2,
+		time.Minute: 1,
+		NoExpiry:    2,
+	})
+
+	// Without buckets every expiring key is counted under 0.
+	assert.Equal(t, cache.ExpiryHistogram(nil), map[time.Duration]int{0: 3, NoExpiry: 2})
+}
+
+func TestDebug(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTickInterval(time.Second))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	cache.Set("xd2", 2, time.Minute)
+	cache.Set("expired", true, time.Millisecond)
+	cache.SetMissing("missing", 0)
+	clock.Advance(time.Millisecond)
+
+	assert.Equal(t, cache.Debug(), `hotcache: 2 keys, 1 with a ttl, tick interval 1s
+  "xd" = xd (no expiry)
+  "xd2" = 2 (ttl 59.999s)
+`)
+}
+
+func TestDebugCapsOutput(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), strings.Repeat("x", 1000), 0)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(cache.Debug(), "\n"), "\n")
+	assert.Equal(t, lines[0], "hotcache: 100 keys, 0 with a ttl, tick interval 100ms")
+	assert.Equal(t, len(lines), debugMaxEntries+2)
+	assert.Equal(t, lines[len(lines)-1], "  ... and 80 more")
+
+	for _, line := range lines[1 : len(lines)-1] {
+		assert.True(t, strings.Contains(line, strings.Repeat("x", debugMaxValueLength)+"... (no expiry)"))
+		assert.True(t, len(line) < 100)
+	}
+}
@@ -0,0 +1,79 @@
+package hotcache
+
+import "time"
+
+// Tiered looks keys up in a small, fast cache before falling back to a larger one, such as an in-process L1 in front of
+// a bigger L2. Keys found in L2 are promoted into L1 so later lookups don't need to reach L2.
+type Tiered struct {
+	l1, l2 *Hotcache
+
+	// The longest a key is kept in L1, 0 if there's no limit.
+	promotionTTL time.Duration
+}
+
+// TieredOption configures a Tiered created with NewTiered.
+type TieredOption func(*Tiered)
+
+// WithPromotionTTL limits how long keys are kept in L1, so it doesn't keep serving a value for long after it's changed
+// in L2. Keys never outlive their expiry in L2 either way. Defaults to no limit, TTLs that aren't positive are ignored.
+func WithPromotionTTL(ttl time.Duration) TieredOption {
+	return func(t *Tiered) {
+		if ttl > 0 {
+			t.promotionTTL = ttl
+		}
+	}
+}
+
+// NewTiered creates a tiered cache that checks l1 before l2. The caches are still owned by the caller, who must stop
+// them once they're done with them.
+func NewTiered(l1, l2 *Hotcache, opts ...TieredOption) *Tiered {
+	t := &Tiered{l1: l1, l2: l2}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Get retrieves a key that isn't expired from L1, otherwise from L2, promoting it into L1 for as long as it has left
+// in L2 or WithPromotionTTL allows, whichever is shorter.
+func (t *Tiered) Get(key string) (interface{}, bool) {
+	if val, ok := t.l1.Get(key); ok {
+		return val, true
+	}
+
+	val, expiresAt, ok := t.l2.GetWithExpiry(key)
+	if !ok {
+		return nil, false
+	}
+
+	ttl := NoExpiry
+	if !expiresAt.IsZero() {
+		ttl = expiresAt.Sub(t.l2.now())
+		if ttl <= 0 {
+			// It expired in the time since it was looked up.
+			return nil, false
+		}
+	}
+	t.l1.Set(key, val, t.l1TTL(ttl))
+	return val, true
+}
+
+// Set adds a key to both caches, see Hotcache.Set. It's kept in L1 for no longer than WithPromotionTTL allows.
+func (t *Tiered) Set(key string, value interface{}, expiration time.Duration) {
+	t.l2.Set(key, value, expiration)
+	t.l1.Set(key, value, t.l1TTL(expiration))
+}
+
+// Delete removes a key from both caches.
+func (t *Tiered) Delete(key string) {
+	t.l2.Delete(key)
+	t.l1.Delete(key)
+}
+
+// l1TTL caps the expiration for a key in L2 to WithPromotionTTL's.
+func (t *Tiered) l1TTL(expiration time.Duration) time.Duration {
+	if t.promotionTTL > 0 && (expiration <= 0 || expiration > t.promotionTTL) {
+		return t.promotionTTL
+	}
+	return expiration
+}
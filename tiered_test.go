@@ -0,0 +1,112 @@
+package hotcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredPromotes(t *testing.T) {
+	clock := newFakeClock()
+	l1 := New(WithClock(clock))
+	defer l1.Stop()
+	l2 := New(WithClock(clock))
+	defer l2.Stop()
+
+	tiered := NewTiered(l1, l2)
+
+	l2.Set("xd", "xd", time.Second)
+	clock.Advance(time.Millisecond * 400)
+
+	val, ok := tiered.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	// The key is promoted for as long as it has left in L2.
+	val, ok = l1.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+	ttl, _ := l1.TTL("xd")
+	assert.Equal(t, ttl, time.Millisecond*600)
+
+	// Later lookups are served by L1.
+	l2.Delete("xd")
+	val, ok = tiered.Get("xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, ok, true)
+
+	l2.Set("forever", "forever", 0)
+	tiered.Get("forever")
+	ttl, _ = l1.TTL("forever")
+	assert.Equal(t, ttl, NoExpiry)
+}
+
+func TestTieredMiss(t *testing.T) {
+	l1 := New()
+	defer l1.Stop()
+	l2 := New()
+	defer l2.Stop()
+
+	tiered := NewTiered(l1, l2)
+
+	val, ok := tiered.Get("xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, l1.LenApprox(), 0)
+}
+
+func TestTieredPromotionTTL(t *testing.T) {
+	clock := newFakeClock()
+	l1 := New(WithClock(clock))
+	defer l1.Stop()
+	l2 := New(WithClock(clock))
+	defer l2.Stop()
+
+	tiered := NewTiered(l1, l2, WithPromotionTTL(time.Second))
+
+	l2.Set("xd", "xd", 0)
+	l2.Set("xd2", "xd2", time.Millisecond*500)
+	tiered.Get("xd")
+	tiered.Get("xd2")
+
+	ttl, _ := l1.TTL("xd")
+	assert.Equal(t, ttl, time.Second)
+	ttl, _ = l1.TTL("xd2")
+	assert.Equal(t, ttl, time.Millisecond*500)
+
+	// Once it expires from L1 it's promoted again from L2.
+	clock.Advance(time.Second)
+	l2.Set("xd", "xd3", 0)
+	val, _ := tiered.Get("xd")
+	assert.Equal(t, val, "xd3")
+}
+
+func TestTieredPromotionTTLIgnoresInvalid(t *testing.T) {
+	tiered := NewTiered(nil, nil, WithPromotionTTL(0), WithPromotionTTL(-1))
+	assert.Equal(t, tiered.promotionTTL, time.Duration(0))
+}
+
+func TestTieredSetAndDelete(t *testing.T) {
+	l1 := New()
+	defer l1.Stop()
+	l2 := New()
+	defer l2.Stop()
+
+	tiered := NewTiered(l1, l2, WithPromotionTTL(time.Second))
+
+	tiered.Set("xd", "xd", time.Hour)
+	val, _ := l1.Get("xd")
+	assert.Equal(t, val, "xd")
+	val, _ = l2.Get("xd")
+	assert.Equal(t, val, "xd")
+
+	ttl, _ := l1.TTL("xd")
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+	ttl, _ = l2.TTL("xd")
+	assert.True(t, ttl > time.Second && ttl <= time.Hour)
+
+	tiered.Delete("xd")
+	assert.Equal(t, l1.Has("xd"), false)
+	assert.Equal(t, l2.Has("xd"), false)
+}
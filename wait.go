@@ -0,0 +1,38 @@
+package hotcache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopped is returned by WaitForKey when the cache is stopped while waiting, or was already stopped.
+var ErrStopped = errors.New("hotcache: cache is stopped")
+
+// WaitForKey retrieves a key like Get, waiting for it to be set if it's missing or expired, until ctx is done. It
+// returns ctx.Err() if the key isn't set in time, or ErrStopped if the cache is stopped. Rather than polling, it waits
+// for the event published when the key is set, the same as Subscribe, so it sees updates such as Increment too, but not
+// negative entries set by SetMissing.
+func (h *Hotcache) WaitForKey(ctx context.Context, key string) (interface{}, error) {
+	// Only one event is needed, any more are dropped.
+	events, unsubscribe := h.subscribe(&subscription{events: make(chan Event, 1), keyed: true, key: key})
+	defer unsubscribe()
+
+	// Subscribing first means the key can't be set between checking for it and waiting.
+	if val, ok := h.Get(key); ok {
+		return val, nil
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil, ErrStopped
+			}
+			if e.Type == EventSet {
+				return e.Value, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
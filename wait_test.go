@@ -0,0 +1,115 @@
+package hotcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForKey(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		cache.Set("other", "xd", 0)
+		cache.Set("xd", "xd", 0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	val, err := cache.WaitForKey(ctx, "xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+}
+
+func TestWaitForKeyExisting(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+
+	// The key is returned straight away, even with a context that's already done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	val, err := cache.WaitForKey(ctx, "xd")
+	assert.Equal(t, val, "xd")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cache.subscriptions.count, int32(0))
+}
+
+func TestWaitForKeyTimeout(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock))
+	defer cache.Stop()
+
+	// Expired keys and negative entries don't count as set.
+	cache.Set("xd", "xd", time.Millisecond*10)
+	clock.Advance(time.Millisecond * 10)
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		cache.SetMissing("xd", 0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	val, err := cache.WaitForKey(ctx, "xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, cache.subscriptions.count, int32(0))
+}
+
+func TestWaitForKeyStop(t *testing.T) {
+	cache := New()
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		cache.Stop()
+	}()
+
+	val, err := cache.WaitForKey(context.Background(), "xd")
+	assert.Equal(t, val, nil)
+	assert.Equal(t, err, ErrStopped)
+
+	_, err = cache.WaitForKey(context.Background(), "xd")
+	assert.Equal(t, err, ErrStopped)
+}
+
+func TestWaitForKeyDoesNotDrop(t *testing.T) {
+	cache := New()
+	defer cache.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		val, err := cache.WaitForKey(ctx, "xd")
+		assert.Equal(t, val, 1)
+		assert.Equal(t, err, nil)
+	}()
+
+	// Wait for the waiter to subscribe, then write the key far more often than a subscriber's buffer holds.
+	for {
+		cache.subscriptions.mutex.RLock()
+		subscribed := len(cache.subscriptions.subs) == 1
+		cache.subscriptions.mutex.RUnlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i <= eventBufferSize*2; i++ {
+		cache.Set("xd", i, 0)
+	}
+
+	<-done
+	assert.Equal(t, cache.Stats().DroppedEvents, uint64(0))
+}
@@ -0,0 +1,146 @@
+package hotcache
+
+import "time"
+
+// timingWheelSlots is the number of slots in each shard's timing wheel, see WithTimingWheel. Keys due further ahead
+// than the wheel spans wrap around, and are skipped over until the round they're due in.
+const timingWheelSlots = 4096
+
+// timingWheel is a hashed timing wheel of when each expiring key in a shard is due to be evicted, so ticks only have to
+// check keys that are due rather than sampling them at random. Time is split into ticks of resolution, with each
+// key held in the slot of the first tick at or after it's due. Each key is only ever held in one slot, moving when
+// it's rescheduled. It isn't safe for concurrent use, shards guard it with their expiry mutex.
+type timingWheel struct {
+	resolution int64
+	slots      []map[string]struct{}
+
+	// The tick each key is due in.
+	scheduled map[string]int64
+
+	// The last tick that's been advanced to, every key due by it has been returned by advance.
+	cursor int64
+}
+
+func newTimingWheel(resolution time.Duration, now time.Time) *timingWheel {
+	w := &timingWheel{
+		resolution: int64(resolution),
+		slots:      make([]map[string]struct{}, timingWheelSlots),
+		scheduled:  make(map[string]int64),
+	}
+	w.cursor = now.UnixNano() / w.resolution
+	return w
+}
+
+// schedule moves a key to the slot for when it's due, keys already due are returned by the next advance.
+func (w *timingWheel) schedule(key string, due time.Time) {
+	n := due.UnixNano()
+	tick := n / w.resolution
+	if n%w.resolution != 0 {
+		tick++
+	}
+	if tick <= w.cursor {
+		tick = w.cursor + 1
+	}
+
+	if old, ok := w.scheduled[key]; ok {
+		if old == tick {
+			return
+		}
+		delete(w.slots[old%timingWheelSlots], key)
+	}
+
+	slot := w.slots[tick%timingWheelSlots]
+	if slot == nil {
+		slot = make(map[string]struct{})
+		w.slots[tick%timingWheelSlots] = slot
+	}
+	slot[key] = struct{}{}
+	w.scheduled[key] = tick
+}
+
+// unschedule removes a key from the wheel, if it's in it.
+func (w *timingWheel) unschedule(key string) {
+	if tick, ok := w.scheduled[key]; ok {
+		delete(w.slots[tick%timingWheelSlots], key)
+		delete(w.scheduled, key)
+	}
+}
+
+// advance moves the wheel forward to now, removing and returning every key that's due by then. Only the slots between
+// the last advance and now are checked, and at most one full turn of the wheel if it's been longer than that.
+func (w *timingWheel) advance(now time.Time) []string {
+	target := now.UnixNano() / w.resolution
+	if target <= w.cursor {
+		return nil
+	}
+
+	ticks := target - w.cursor
+	if ticks > timingWheelSlots {
+		ticks = timingWheelSlots
+	}
+
+	var due []string
+	for i := int64(1); i <= ticks; i++ {
+		slot := w.slots[(w.cursor+i)%timingWheelSlots]
+		for key := range slot {
+			// Keys in later rounds stay where they are.
+			if w.scheduled[key] > target {
+				continue
+			}
+			due = append(due, key)
+			delete(slot, key)
+			delete(w.scheduled, key)
+		}
+	}
+
+	w.cursor = target
+	return due
+}
+
+// reset removes every key from the wheel.
+func (w *timingWheel) reset() {
+	w.slots = make([]map[string]struct{}, timingWheelSlots)
+	w.scheduled = make(map[string]int64)
+}
+
+// scheduleExpiry schedules a value that's just been stored to be evicted by the timing wheel, doing nothing without
+// WithTimingWheel or for values without an expiry. Assumes the store mutex is held.
+func (h *Hotcache) scheduleExpiry(s *shard, key string, val *cacheValue) {
+	if s.wheel == nil || val.expiry.IsZero() {
+		return
+	}
+
+	s.expiryMutex.Lock()
+	s.wheel.schedule(key, h.evictionTime(val))
+	s.expiryMutex.Unlock()
+}
+
+// tickWheel evicts every key in a shard that the timing wheel says is due, returning how many keys it checked and how
+// many of those were evicted.
+func (h *Hotcache) tickWheel(s *shard, now time.Time) (checked int, evicted int) {
+	s.expiryMutex.Lock()
+	due := s.wheel.advance(now)
+	s.expiryMutex.Unlock()
+
+	for _, key := range due {
+		// As with tickShard, evicted keys are already untracked and may have been set again since.
+		checked++
+		if h.attemptEviction(s, key) {
+			evicted++
+			continue
+		}
+
+		// The key was set again since it was taken off the wheel, which normally schedules it again anyway, but make
+		// sure it isn't lost.
+		s.storeMutex.RLock()
+		val, ok := s.store[key]
+		s.storeMutex.RUnlock()
+		if ok && !val.expiry.IsZero() {
+			s.expiryMutex.Lock()
+			s.wheel.schedule(key, h.evictionTime(val))
+			s.expiryMutex.Unlock()
+		}
+	}
+
+	return checked, evicted
+}
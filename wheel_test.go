@@ -0,0 +1,241 @@
+package hotcache
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimingWheel(t *testing.T) {
+	start := time.Unix(1000, 0)
+	w := newTimingWheel(time.Second, start)
+
+	w.schedule("a", start.Add(time.Second))
+	w.schedule("b", start.Add(time.Millisecond*1500))
+	w.schedule("c", start.Add(time.Second*3))
+	w.schedule("d", start.Add(time.Second*3))
+	w.unschedule("d")
+
+	assert.Equal(t, len(w.advance(start.Add(time.Millisecond*999))), 0)
+	assert.Equal(t, w.advance(start.Add(time.Second)), []string{"a"})
+
+	// Keys are due in the first slot after their deadline.
+	assert.Equal(t, len(w.advance(start.Add(time.Millisecond*1900))), 0)
+	assert.Equal(t, w.advance(start.Add(time.Second*2)), []string{"b"})
+
+	// Rescheduling moves a key to its new slot.
+	w.schedule("c", start.Add(time.Second*5))
+	assert.Equal(t, len(w.advance(start.Add(time.Second*4))), 0)
+	assert.Equal(t, w.advance(start.Add(time.Second*5)), []string{"c"})
+
+	// Keys that are already due are returned by the next advance.
+	w.schedule("e", start)
+	assert.Equal(t, w.advance(start.Add(time.Second*6)), []string{"e"})
+	assert.Equal(t, len(w.scheduled), 0)
+}
+
+func TestTimingWheelRounds(t *testing.T) {
+	start := time.Unix(1000, 0)
+	w := newTimingWheel(time.Second, start)
+
+	// Both keys share a slot, a round of the wheel apart.
+	w.schedule("a", start.Add(time.Second))
+	w.schedule("b", start.Add(time.Second*(timingWheelSlots+1)))
+
+	assert.Equal(t, w.advance(start.Add(time.Second)), []string{"a"})
+	assert.Equal(t, len(w.advance(start.Add(time.Second*timingWheelSlots))), 0)
+	assert.Equal(t, w.advance(start.Add(time.Second*(timingWheelSlots+1))), []string{"b"})
+
+	// Advancing further than a full turn still finds every due key.
+	w.schedule("c", start.Add(time.Second*(timingWheelSlots+10)))
+	w.schedule("d", start.Add(time.Second*(timingWheelSlots+20)))
+	due := w.advance(start.Add(time.Second * timingWheelSlots * 5))
+	sort.Strings(due)
+	assert.Equal(t, due, []string{"c", "d"})
+}
+
+func TestWithTimingWheel(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTimingWheel(time.Millisecond*100), WithGCBatchSize(1))
+	defer cache.Stop()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Second)
+	}
+	cache.Set("later", true, time.Second*2)
+	cache.Set("forever", true, 0)
+	cache.Set("extended", true, time.Second)
+	cache.Expire("extended", time.Second*3)
+	cache.Set("persisted", true, time.Second)
+	cache.Persist("persisted")
+	assertExpiryTracked(t, cache)
+
+	clock.Advance(time.Millisecond * 900)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 104)
+
+	// Every expired key is evicted at once, however small the batch size.
+	clock.Advance(time.Millisecond * 100)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 4)
+	assertExpiryTracked(t, cache)
+
+	clock.Advance(time.Second)
+	cache.tick()
+	assert.Equal(t, cache.Has("later"), false)
+	assert.Equal(t, cache.LenApprox(), 3)
+
+	clock.Advance(time.Second)
+	cache.tick()
+	keys := cache.Keys()
+	sort.Strings(keys)
+	assert.Equal(t, keys, []string{"forever", "persisted"})
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithTimingWheelTouch(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTimingWheel(time.Millisecond*100))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Millisecond * 500)
+	cache.Touch("xd")
+
+	clock.Advance(time.Millisecond * 500)
+	cache.tick()
+	assert.Equal(t, cache.Has("xd"), true)
+
+	clock.Advance(time.Millisecond * 500)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithTimingWheelStaleWindow(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTimingWheel(time.Millisecond*100), WithStaleWindow(time.Second))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.SetWithSoftTTL("soft", "soft", time.Second, time.Second*3)
+
+	clock.Advance(time.Millisecond * 1500)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 2)
+
+	clock.Advance(time.Millisecond * 500)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 1)
+	_, stale, ok := cache.GetStale("soft")
+	assert.Equal(t, stale, true)
+	assert.Equal(t, ok, true)
+
+	clock.Advance(time.Second)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithTimingWheelResetFromOnEvict(t *testing.T) {
+	clock := newFakeClock()
+	var cache *Hotcache
+	reset := false
+	cache = New(WithClock(clock), WithTimingWheel(time.Millisecond*100), WithOnEvict(func(key string, value interface{}, reason EvictReason) {
+		if reason == ReasonExpired && !reset {
+			reset = true
+			cache.Set(key, "xd2", time.Second)
+		}
+	}))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	clock.Advance(time.Second)
+	cache.tick()
+
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd2")
+	assertExpiryTracked(t, cache)
+
+	// The key is scheduled again, so the wheel still collects it.
+	clock.Advance(time.Second)
+	cache.tick()
+	assert.Equal(t, cache.LenApprox(), 0)
+	assertExpiryTracked(t, cache)
+}
+
+func TestWithTimingWheelClear(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(WithClock(clock), WithTimingWheel(time.Millisecond*100))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Clear()
+	for _, s := range cache.shards {
+		assert.Equal(t, len(s.wheel.scheduled), 0)
+	}
+
+	cache.Set("xd", "xd", time.Second)
+	cache.Delete("xd")
+	for _, s := range cache.shards {
+		assert.Equal(t, len(s.wheel.scheduled), 0)
+	}
+}
+
+func TestWithTimingWheelIgnoresInvalid(t *testing.T) {
+	cache := New(WithTimingWheel(0))
+	defer cache.Stop()
+
+	assert.Equal(t, cache.options.wheelResolution, time.Duration(0))
+	assert.Nil(t, cache.shards[0].wheel)
+}
+
+// benchmarkExpiryChurn sets keys that expire almost straight away at a high rate, with a backlog of keys that don't,
+// and ticks after each batch. Alongside the time for the whole batch, it reports the time spent ticking, and how many
+// expired keys were still in cache after the last tick, as a measure of how long expired keys wait to be evicted.
+func benchmarkExpiryChurn(b *testing.B, opts ...Option) {
+	clock := newFakeClock()
+	cache := New(append([]Option{WithClock(clock)}, opts...)...)
+	defer cache.Stop()
+
+	for i := 0; i < 100000; i++ {
+		cache.Set("backlog"+strconv.Itoa(i), i, time.Hour)
+	}
+
+	const batch = 1000
+	keys := make([]string, batch)
+	for i := range keys {
+		keys[i] = "churn" + strconv.Itoa(i)
+	}
+
+	var ticking time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			cache.Set(key+"-"+strconv.Itoa(i), i, time.Millisecond*50)
+		}
+		clock.Advance(time.Millisecond * 100)
+		start := time.Now()
+		cache.tick()
+		ticking += time.Since(start)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(ticking.Nanoseconds())/float64(b.N), "tick-ns/op")
+	b.ReportMetric(float64(cache.LenApprox()-100000), "expired-keys-left")
+}
+
+func BenchmarkExpiryChurnSampler(b *testing.B) {
+	benchmarkExpiryChurn(b)
+}
+
+func BenchmarkExpiryChurnAdaptive(b *testing.B) {
+	benchmarkExpiryChurn(b, WithAdaptiveGC(true))
+}
+
+func BenchmarkExpiryChurnTimingWheel(b *testing.B) {
+	benchmarkExpiryChurn(b, WithTimingWheel(time.Millisecond*100))
+}
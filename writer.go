@@ -0,0 +1,152 @@
+package hotcache
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// writeBehindAttempts is how many times WriteBehind tries a write before giving up on it.
+const writeBehindAttempts = 3
+
+// writeBehindRetryDelay is how long WriteBehind waits before retrying a failed write, doubling after each attempt.
+const writeBehindRetryDelay = time.Millisecond * 100
+
+// Writer persists values set in cache to a backing store, see WithWriter.
+type Writer interface {
+	Write(key string, value interface{}) error
+}
+
+// WriteMode decides when values set in cache are written to the Writer set by WithWriter.
+type WriteMode int
+
+const (
+	// WriteThrough writes each value before it's cached, so a value that fails to be written isn't cached.
+	WriteThrough WriteMode = iota
+	// WriteBehind caches each value straight away and writes it from a background goroutine, retrying failed writes.
+	WriteBehind
+)
+
+// String returns the name of the mode.
+func (m WriteMode) String() string {
+	switch m {
+	case WriteThrough:
+		return "write-through"
+	case WriteBehind:
+		return "write-behind"
+	default:
+		return "unknown"
+	}
+}
+
+// pendingWrite is a value waiting to be written by WriteBehind.
+type pendingWrite struct {
+	key   string
+	value interface{}
+}
+
+// writeQueue holds the values waiting to be written by WriteBehind, in the order they were set.
+type writeQueue struct {
+	mutex   sync.Mutex
+	pending []pendingWrite
+
+	// Signals the writer goroutine that there are writes pending, it never blocks so only holds one signal.
+	wake chan struct{}
+
+	// Only one flush runs at a time, so writes are never reordered.
+	flushMutex sync.Mutex
+}
+
+func newWriteQueue() *writeQueue {
+	return &writeQueue{wake: make(chan struct{}, 1)}
+}
+
+// writeThrough writes a value that's about to be cached with WriteThrough, doing nothing in any other mode.
+func (h *Hotcache) writeThrough(key string, value interface{}) error {
+	if h.options.writer == nil || h.options.writeMode != WriteThrough {
+		return nil
+	}
+	return h.options.writer.Write(key, value)
+}
+
+// queueWrite queues a value that's just been cached to be written with WriteBehind, doing nothing in any other mode.
+// The store mutex of the key's shard must be held, so writes to the same key are queued in the order they're cached.
+func (h *Hotcache) queueWrite(key string, value interface{}) {
+	q := h.writes
+	if q == nil {
+		return
+	}
+
+	q.mutex.Lock()
+	q.pending = append(q.pending, pendingWrite{key: key, value: value})
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeBehind writes queued values as they're set until done is closed, Stop then writes whatever's left.
+func (h *Hotcache) writeBehind(done <-chan struct{}) {
+	defer h.background.Done()
+
+	for {
+		select {
+		case <-h.writes.wake:
+			h.flushWrites(done)
+		case <-done:
+			return
+		}
+	}
+}
+
+// flushWrites writes every queued value, including any queued while it's running. Failed writes are retried until
+// they've been attempted writeBehindAttempts times, and once done is closed they're retried without waiting.
+func (h *Hotcache) flushWrites(done <-chan struct{}) {
+	q := h.writes
+	q.flushMutex.Lock()
+	defer q.flushMutex.Unlock()
+
+	for {
+		q.mutex.Lock()
+		pending := q.pending
+		q.pending = nil
+		q.mutex.Unlock()
+
+		if len(pending) == 0 {
+			return
+		}
+		for _, w := range pending {
+			h.write(w, done)
+		}
+	}
+}
+
+// write writes a single queued value, retrying it if it fails, see flushWrites.
+func (h *Hotcache) write(w pendingWrite, done <-chan struct{}) {
+	delay := writeBehindRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := h.options.writer.Write(w.key, w.value)
+		if err == nil {
+			return
+		}
+
+		if attempt == writeBehindAttempts {
+			if h.debugEnabled() {
+				h.debug("hotcache: write failed",
+					slog.String("key", w.key),
+					slog.Int("attempts", attempt),
+					slog.Any("error", err),
+				)
+			}
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-done:
+		}
+		delay *= 2
+	}
+}
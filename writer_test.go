@@ -0,0 +1,276 @@
+package hotcache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRecorder is a Writer that records every write, failing the first failures attempts at writing each key.
+type writeRecorder struct {
+	mutex    sync.Mutex
+	writes   []pendingWrite
+	attempts map[string]int
+	failures int
+	release  chan struct{}
+}
+
+var errWriteFailed = errors.New("backend down")
+
+func newWriteRecorder(failures int) *writeRecorder {
+	return &writeRecorder{attempts: make(map[string]int), failures: failures}
+}
+
+func (w *writeRecorder) Write(key string, value interface{}) error {
+	if w.release != nil {
+		<-w.release
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.attempts[key]++
+	if w.attempts[key] <= w.failures {
+		return errWriteFailed
+	}
+	w.writes = append(w.writes, pendingWrite{key: key, value: value})
+	return nil
+}
+
+func (w *writeRecorder) get() []pendingWrite {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return append([]pendingWrite(nil), w.writes...)
+}
+
+func (w *writeRecorder) getAttempts(key string) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.attempts[key]
+}
+
+func TestWriteMode(t *testing.T) {
+	assert.Equal(t, WriteThrough.String(), "write-through")
+	assert.Equal(t, WriteBehind.String(), "write-behind")
+	assert.Equal(t, WriteMode(100).String(), "unknown")
+}
+
+func TestWriteThrough(t *testing.T) {
+	writer := newWriteRecorder(0)
+	cache := New(WithWriter(writer, WriteThrough))
+	defer cache.Stop()
+
+	// Values are written before Set returns.
+	cache.Set("xd", "xd", 0)
+	cache.SetDefault("xd2", "xd2")
+	assert.Equal(t, cache.SetChecked("xd3", "xd3", 0), nil)
+	assert.Equal(t, writer.get(), []pendingWrite{{"xd", "xd"}, {"xd2", "xd2"}, {"xd3", "xd3"}})
+	assert.ElementsMatch(t, cache.Keys(), []string{"xd", "xd2", "xd3"})
+}
+
+// writePaths sets keys through every method WithWriter writes values from.
+var writePaths = map[string]func(h *Hotcache){
+	"Set":         func(h *Hotcache) { h.Set("xd", "xd", 0) },
+	"SetDefault":  func(h *Hotcache) { h.SetDefault("xd", "xd") },
+	"SetChecked":  func(h *Hotcache) { h.SetChecked("xd", "xd", 0) },
+	"SetWithCost": func(h *Hotcache) { h.SetWithCost("xd", "xd", 1, 0) },
+	"SetMulti": func(h *Hotcache) {
+		h.SetMulti(map[string]interface{}{"xd": "xd"}, time.Second)
+	},
+	"SetMultiWithTTLs": func(h *Hotcache) {
+		h.SetMultiWithTTLs(map[string]ValueTTL{"xd": {Value: "xd", Expiration: time.Second}})
+	},
+	"SetManyWithPolicy": func(h *Hotcache) {
+		h.SetManyWithPolicy(map[string]interface{}{"xd": "xd"}, time.Second, OverwriteAlways)
+	},
+	"SetWithSoftTTL": func(h *Hotcache) { h.SetWithSoftTTL("xd", "xd", time.Second, time.Second*2) },
+	"GetOrCompute": func(h *Hotcache) {
+		h.GetOrCompute("xd", 0, func() (interface{}, error) { return "xd", nil })
+	},
+	"ReadThrough": func(h *Hotcache) {
+		r := &ReadThrough{cache: h, loader: func(string) (interface{}, time.Duration, error) { return "xd", 0, nil }}
+		r.Get("xd")
+	},
+}
+
+func TestWriterPaths(t *testing.T) {
+	for _, mode := range []WriteMode{WriteThrough, WriteBehind} {
+		for name, path := range writePaths {
+			t.Run(mode.String()+"/"+name, func(t *testing.T) {
+				writer := newWriteRecorder(0)
+				cache := New(WithWriter(writer, mode))
+				defer cache.Stop()
+
+				path(cache)
+				eventually(t, func() bool {
+					return len(writer.get()) == 1
+				})
+				assert.Equal(t, writer.get(), []pendingWrite{{"xd", "xd"}})
+				assert.Equal(t, cache.Has("xd"), true)
+			})
+		}
+	}
+}
+
+func TestWriterPathsError(t *testing.T) {
+	for name, path := range writePaths {
+		t.Run(name, func(t *testing.T) {
+			writer := newWriteRecorder(1)
+			cache := New(WithWriter(writer, WriteThrough))
+			defer cache.Stop()
+
+			// Values that fail to be written aren't cached.
+			path(cache)
+			assert.Equal(t, writer.getAttempts("xd"), 1)
+			assert.Equal(t, cache.Has("xd"), false)
+		})
+	}
+}
+
+func TestWriteThroughSetMultiError(t *testing.T) {
+	writer := newWriteRecorder(0)
+	cache := New(WithWriter(&failingKeyWriter{writeRecorder: writer, fail: "xd"}, WriteThrough))
+	defer cache.Stop()
+
+	// Only the entries that fail to be written are left out.
+	cache.SetMulti(map[string]interface{}{"xd": "xd", "xd2": "xd2"}, 0)
+	assert.Equal(t, cache.Keys(), []string{"xd2"})
+	assert.Equal(t, writer.get(), []pendingWrite{{"xd2", "xd2"}})
+}
+
+func TestWriteThroughSetManyWithPolicyError(t *testing.T) {
+	writer := newWriteRecorder(0)
+	cache := New(WithWriter(&failingKeyWriter{writeRecorder: writer, fail: "xd"}, WriteThrough))
+	defer cache.Stop()
+
+	// Entries that fail to be written are reported as not written.
+	written := cache.SetManyWithPolicy(map[string]interface{}{"xd": "xd", "xd2": "xd2"}, 0, OverwriteAlways)
+	assert.Equal(t, written, map[string]bool{"xd": false, "xd2": true})
+	assert.Equal(t, cache.Keys(), []string{"xd2"})
+	assert.Equal(t, writer.get(), []pendingWrite{{"xd2", "xd2"}})
+}
+
+// failingKeyWriter is a writeRecorder that always fails to write one key.
+type failingKeyWriter struct {
+	*writeRecorder
+	fail string
+}
+
+func (w *failingKeyWriter) Write(key string, value interface{}) error {
+	if key == w.fail {
+		return errWriteFailed
+	}
+	return w.writeRecorder.Write(key, value)
+}
+
+func TestWriterUnsupportedPaths(t *testing.T) {
+	for _, mode := range []WriteMode{WriteThrough, WriteBehind} {
+		writer := newWriteRecorder(0)
+		cache := New(WithWriter(writer, mode))
+
+		// Only changes made by the methods WithWriter lists are written.
+		cache.SetNX("xd", "xd", 0)
+		cache.GetOrSet("xd2", "xd2", 0)
+		cache.Swap("xd", "xd3", 0)
+		cache.Update("xd", func(interface{}, bool) (interface{}, time.Duration, bool) { return "xd4", 0, true })
+		cache.Increment("count", 1)
+		cache.Batch().Set("xd5", "xd5", 0).Exec()
+		cache.SetManyWithPolicy(map[string]interface{}{"xd6": "xd6"}, 0, OverwriteNever)
+		cache.HSet("hash", "field", "xd", 0)
+		cache.Delete("xd")
+
+		cache.Stop()
+		assert.Len(t, writer.get(), 0, mode.String())
+	}
+}
+
+func TestWriteThroughError(t *testing.T) {
+	writer := newWriteRecorder(1)
+	cache := New(WithWriter(writer, WriteThrough))
+	defer cache.Stop()
+
+	// A value that fails to be written isn't cached.
+	assert.Equal(t, cache.SetChecked("xd", "xd", 0), errWriteFailed)
+	assert.Equal(t, cache.Has("xd"), false)
+
+	assert.Equal(t, cache.SetChecked("xd", "xd", 0), nil)
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, "xd")
+
+	// Nor does it replace a cached value.
+	writer.mutex.Lock()
+	writer.attempts["xd"] = 0
+	writer.mutex.Unlock()
+	cache.Set("xd", "xd2", 0)
+	val, _ = cache.Get("xd")
+	assert.Equal(t, val, "xd")
+}
+
+func TestWriteBehind(t *testing.T) {
+	writer := newWriteRecorder(0)
+	writer.release = make(chan struct{})
+	cache := New(WithWriter(writer, WriteBehind))
+	defer cache.Stop()
+
+	// Set doesn't wait on the writer.
+	for i := 0; i < 10; i++ {
+		cache.Set("xd", i, 0)
+	}
+	val, _ := cache.Get("xd")
+	assert.Equal(t, val, 9)
+	assert.Len(t, writer.get(), 0)
+
+	// Values are written in the order they were set.
+	close(writer.release)
+	eventually(t, func() bool {
+		return len(writer.get()) == 10
+	})
+	for i, w := range writer.get() {
+		assert.Equal(t, w, pendingWrite{key: "xd", value: i})
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	writer := newWriteRecorder(2)
+	cache := New(WithWriter(writer, WriteBehind))
+	defer cache.Stop()
+
+	cache.Set("xd", "xd", 0)
+	eventually(t, func() bool {
+		return len(writer.get()) == 1
+	})
+	assert.Equal(t, writer.getAttempts("xd"), 3)
+}
+
+func TestWriteBehindStop(t *testing.T) {
+	writer := newWriteRecorder(0)
+	cache := New(WithWriter(writer, WriteBehind))
+
+	for i := 0; i < 100; i++ {
+		cache.Set("xd"+strconv.Itoa(i), i, 0)
+	}
+
+	// Stop writes everything still queued.
+	cache.Stop()
+	assert.Len(t, writer.get(), 100)
+}
+
+func TestWriteBehindStopGivesUp(t *testing.T) {
+	writer := newWriteRecorder(writeBehindAttempts)
+	cache := New(WithWriter(writer, WriteBehind), WithStartPaused(true))
+
+	cache.Set("xd", "xd", 0)
+
+	// Failed writes are retried without waiting, until they've been attempted enough times.
+	start := time.Now()
+	cache.Stop()
+	assert.Less(t, int64(time.Since(start)), int64(writeBehindRetryDelay))
+	assert.Equal(t, writer.getAttempts("xd"), writeBehindAttempts)
+	assert.Len(t, writer.get(), 0)
+}